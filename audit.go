@@ -0,0 +1,189 @@
+package marmotcoreclient
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+type AuditFindingKind string
+
+const (
+	KeyForDeletedNode     AuditFindingKind = "key_for_deleted_node"
+	KeyWithoutNode        AuditFindingKind = "key_without_node"
+	DuplicateKey          AuditFindingKind = "duplicate_key"
+	RunningNodeWithoutKey AuditFindingKind = "running_node_without_key"
+	UserIdMismatch        AuditFindingKind = "user_id_mismatch"
+)
+
+var auditSeverities = map[AuditFindingKind]Severity{
+	KeyForDeletedNode:     SeverityWarning,
+	KeyWithoutNode:        SeverityWarning,
+	DuplicateKey:          SeverityWarning,
+	RunningNodeWithoutKey: SeverityCritical,
+	UserIdMismatch:        SeverityCritical,
+}
+
+type AuditFinding struct {
+	NodeId   string           `json:"node_id"`
+	Kind     AuditFindingKind `json:"kind"`
+	Severity Severity         `json:"severity"`
+	Message  string           `json:"message"`
+}
+
+type AuditReport struct {
+	Findings []AuditFinding `json:"findings"`
+}
+
+// MaxSeverity is the most severe finding, or SeverityInfo if there are none.
+func (r AuditReport) MaxSeverity() Severity {
+	max := SeverityInfo
+	for _, finding := range r.Findings {
+		if finding.Severity > max {
+			max = finding.Severity
+		}
+	}
+	return max
+}
+
+func (r *AuditReport) add(nodeId string, kind AuditFindingKind, format string, args ...interface{}) {
+	r.Findings = append(r.Findings, AuditFinding{
+		NodeId:   nodeId,
+		Kind:     kind,
+		Severity: auditSeverities[kind],
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Audit joins nodes and keys by NodeId and reports keys for deleted or
+// unknown nodes, duplicate keys, running nodes without a key and user ID
+// mismatches, most severe first.
+func Audit(nodes []Node, keys []Key) AuditReport {
+	var report AuditReport
+
+	nodesById := map[string]Node{}
+	for _, node := range nodes {
+		nodesById[node.NodeId] = node
+	}
+
+	keysById := map[string][]Key{}
+	for _, key := range keys {
+		keysById[key.NodeId] = append(keysById[key.NodeId], key)
+	}
+
+	for _, nodeId := range sortedKeys(keysById) {
+		nodeKeys := keysById[nodeId]
+		node, ok := nodesById[nodeId]
+
+		if len(nodeKeys) > 1 {
+			report.add(nodeId, DuplicateKey, "%d keys returned for node", len(nodeKeys))
+		}
+
+		switch {
+		case !ok:
+			report.add(nodeId, KeyWithoutNode, "key for a node that GetNodes does not return")
+		case node.Deleted:
+			report.add(nodeId, KeyForDeletedNode, "key for a node deleted at %d", node.DeletedTime)
+		}
+
+		for _, key := range nodeKeys {
+			if ok && key.UserId != node.UserId {
+				report.add(nodeId, UserIdMismatch, "key belongs to user %q but node to %q", key.UserId, node.UserId)
+			}
+		}
+	}
+
+	for _, nodeId := range sortedKeys(nodesById) {
+		node := nodesById[nodeId]
+		if node.State == "R" && !node.Deleted && len(keysById[nodeId]) == 0 {
+			report.add(nodeId, RunningNodeWithoutKey, "running node has no key")
+		}
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		return report.Findings[i].Severity > report.Findings[j].Severity
+	})
+
+	return report
+}
+
+func (mc MarmotcoreClient) Audit() (AuditReport, error) {
+	nodes, err := mc.fleetNodes()
+	if err != nil {
+		return AuditReport{}, err
+	}
+
+	keys, err := mc.GetKeys()
+	if err != nil {
+		return AuditReport{}, err
+	}
+
+	return Audit(nodes, keys.Keys), nil
+}
+
+// LocalKeyStore is a local copy of keys, such as a Vault, that keys can be
+// purged from.
+type LocalKeyStore interface {
+	List() []string
+	Remove(nodeId string) error
+}
+
+// PurgeDeletedNodeKeys removes the keys in store whose node GetNodes reports
+// deleted or doesn't return at all, and returns the node IDs that were
+// removed. Nothing is removed if the nodes can't be listed.
+func (mc MarmotcoreClient) PurgeDeletedNodeKeys(store LocalKeyStore) ([]string, error) {
+	nodes, err := mc.fleetNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	live := map[string]bool{}
+	for _, node := range nodes {
+		if !node.Deleted {
+			live[node.NodeId] = true
+		}
+	}
+
+	var purged []string
+	for _, nodeId := range store.List() {
+		if live[nodeId] {
+			continue
+		}
+
+		err := store.Remove(nodeId)
+		if errors.Is(err, ErrKeyNotInVault) {
+			continue
+		}
+		if err != nil {
+			return purged, err
+		}
+
+		purged = append(purged, nodeId)
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,85 @@
+package marmotcoreclient
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudit(t *testing.T) {
+	nodes := []Node{
+		*newNode("testUserId", 1648394251715, "healthy", "54.71.136.33", "us-west-2", "node.small", "1.3.*", "testnet", "R", false),
+		*newNode("testUserId", 1648394251715, "deleted", "54.71.136.34", "us-west-2", "node.small", "1.3.*", "testnet", "T", true),
+		*newNode("testUserId", 1648394251715, "keyless", "54.71.136.35", "us-west-2", "node.small", "1.3.*", "testnet", "R", false),
+		*newNode("testUserId", 1648394251715, "starting", "", "us-west-2", "node.small", "1.3.*", "testnet", "P", false),
+		*newNode("otherUserId", 1648394251715, "mismatch", "54.71.136.36", "us-west-2", "node.small", "1.3.*", "testnet", "R", false),
+	}
+	keys := []Key{
+		*newKey("testUserId", "healthy", "key", "cert"),
+		*newKey("testUserId", "deleted", "key", "cert"),
+		*newKey("testUserId", "unknown", "key", "cert"),
+		*newKey("testUserId", "unknown", "key", "cert"),
+		*newKey("testUserId", "mismatch", "key", "cert"),
+	}
+
+	report := Audit(nodes, keys)
+
+	assert.Equal(t, []AuditFinding{
+		{NodeId: "mismatch", Kind: UserIdMismatch, Severity: SeverityCritical, Message: `key belongs to user "testUserId" but node to "otherUserId"`},
+		{NodeId: "keyless", Kind: RunningNodeWithoutKey, Severity: SeverityCritical, Message: "running node has no key"},
+		{NodeId: "deleted", Kind: KeyForDeletedNode, Severity: SeverityWarning, Message: "key for a node deleted at 0"},
+		{NodeId: "unknown", Kind: DuplicateKey, Severity: SeverityWarning, Message: "2 keys returned for node"},
+		{NodeId: "unknown", Kind: KeyWithoutNode, Severity: SeverityWarning, Message: "key for a node that GetNodes does not return"},
+	}, report.Findings)
+	assert.Equal(t, SeverityCritical, report.MaxSeverity())
+	assert.Equal(t, SeverityInfo, Audit(nodes[:1], keys[:1]).MaxSeverity())
+}
+
+func TestClientAuditFailsWhenNodesUnknown(t *testing.T) {
+	GetFunc = func(url string) (*http.Response, error) {
+		return jsonResponse(http.StatusInternalServerError, `{}`, nil), nil
+	}
+	Client = &MockClient{}
+
+	_, err := MarmotcoreClient{}.Audit()
+	assert.EqualError(t, err, "listing nodes: status 500")
+}
+
+func TestPurgeDeletedNodeKeys(t *testing.T) {
+	vault, err := OpenVaultWithKey(filepath.Join(t.TempDir(), "vault.json"), make([]byte, 32))
+	assert.NoError(t, err)
+	vault.Add(
+		*newKey("testUserId", "deleted", "key", "cert"),
+		*newKey("testUserId", "gone", "key", "cert"),
+		*newKey("testUserId", "healthy", "key", "cert"),
+	)
+
+	mockNodesAndKeys(t, []Node{
+		*newNode("testUserId", 1648394251715, "healthy", "54.71.136.33", "us-west-2", "node.small", "1.3.*", "testnet", "R", false),
+		*newNode("testUserId", 1648394251715, "deleted", "54.71.136.34", "us-west-2", "node.small", "1.3.*", "testnet", "T", true),
+	}, nil)
+
+	purged, err := MarmotcoreClient{}.PurgeDeletedNodeKeys(vault)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"deleted", "gone"}, purged)
+	assert.Equal(t, []string{"healthy"}, vault.List())
+}
+
+func TestPurgeDeletedNodeKeysKeepsKeysWhenNodesUnknown(t *testing.T) {
+	vault, err := OpenVaultWithKey(filepath.Join(t.TempDir(), "vault.json"), make([]byte, 32))
+	assert.NoError(t, err)
+	vault.Add(*newKey("testUserId", "healthy", "key", "cert"))
+
+	GetFunc = func(url string) (*http.Response, error) {
+		return jsonResponse(http.StatusInternalServerError, `{}`, nil), nil
+	}
+	Client = &MockClient{}
+
+	_, err = MarmotcoreClient{}.PurgeDeletedNodeKeys(vault)
+
+	assert.Error(t, err)
+	assert.Equal(t, []string{"healthy"}, vault.List())
+}
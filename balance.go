@@ -0,0 +1,189 @@
+package marmotcoreclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrNoRunningNode   = errors.New("no running node")
+	ErrNetworkRequired = errors.New("network is required")
+)
+
+// RunningNodes returns the nodes that are running and have a public IP,
+// optionally only those on network. Network aliases such as testnet match
+// the network they stand for. Services that treat the nodes as
+// interchangeable need a network, since the fleet can span several.
+func (mc MarmotcoreClient) RunningNodes(network string) ([]Node, error) {
+	nodes, err := mc.fleetNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	want := canonicalNetwork(network)
+
+	var running []Node
+	for _, node := range nodes {
+		if node.State != "R" || node.Deleted || node.PublicIp == "" {
+			continue
+		}
+		if network != "" && canonicalNetwork(node.Network) != want {
+			continue
+		}
+		running = append(running, node)
+	}
+
+	return running, nil
+}
+
+func canonicalNetwork(name string) string {
+	if network, err := LookupNetwork(name); err == nil {
+		return network.Name
+	}
+	return name
+}
+
+// Balance is the coin set of one address. Confirmed is the total of every
+// coin record returned, which includes spent coins when the query asks for
+// them; Unspent is the total of unspent coins only.
+type Balance struct {
+	Address    string       `json:"address"`
+	PuzzleHash PuzzleHash   `json:"puzzle_hash"`
+	Confirmed  uint64       `json:"confirmed"`
+	Unspent    uint64       `json:"unspent"`
+	Coins      []CoinRecord `json:"coins"`
+}
+
+// BalanceService looks up address balances on the fleet's own full nodes
+// instead of a wallet. It uses NodeId when set and otherwise the running
+// nodes on Network in turn until one answers.
+type BalanceService struct {
+	Client  MarmotcoreClient
+	RPC     RPCOptions
+	Network string
+	NodeId  string
+}
+
+// ResolveAddresses turns addresses, or puzzle hashes in hex, into puzzle
+// hashes, checking addresses are for the service's network: NodeId's
+// network when it is set, otherwise Network.
+func (s BalanceService) ResolveAddresses(addresses []string) ([]PuzzleHash, error) {
+	puzzleHashes := make([]PuzzleHash, 0, len(addresses))
+	network, resolved := s.Network, s.NodeId == ""
+
+	for _, address := range addresses {
+		var puzzleHash PuzzleHash
+		var err error
+
+		if strings.HasPrefix(address, "0x") || len(address) == 64 {
+			puzzleHash, err = ParsePuzzleHash(address)
+		} else {
+			if !resolved {
+				nodes, err := s.nodes()
+				if err != nil {
+					return nil, err
+				}
+				network, resolved = nodes[0].Network, true
+			}
+			puzzleHash, err = DecodeAddressForNetwork(address, network)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		puzzleHashes = append(puzzleHashes, puzzleHash)
+	}
+
+	return puzzleHashes, nil
+}
+
+func (s BalanceService) nodes() ([]Node, error) {
+	if s.NodeId != "" {
+		node, err := s.Client.GetNode(s.NodeId)
+		if err != nil {
+			return nil, err
+		}
+		if node.Node.NodeId == "" {
+			return nil, fmt.Errorf("%w: %s", ErrNodeNotFound, s.NodeId)
+		}
+		return []Node{node.Node}, nil
+	}
+
+	if s.Network == "" {
+		return nil, fmt.Errorf("balance service: %w without a node id", ErrNetworkRequired)
+	}
+
+	nodes, err := s.Client.RunningNodes(s.Network)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("%w on %s", ErrNoRunningNode, s.Network)
+	}
+	return nodes, nil
+}
+
+// CoinRecords queries get_coin_records_by_puzzle_hashes on the first node
+// that answers.
+func (s BalanceService) CoinRecords(ctx context.Context, puzzleHashes []PuzzleHash, query CoinQuery) ([]CoinRecord, error) {
+	nodes, err := s.nodes()
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, node := range nodes {
+		rpc, err := s.Client.RPCClientForNode(node, s.RPC)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		records, err := rpc.GetCoinRecordsByPuzzleHashes(ctx, puzzleHashes, query)
+		rpc.Close()
+		if err == nil {
+			return records, nil
+		}
+		lastErr = err
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, lastErr
+}
+
+// Balances returns one Balance per address, in the order given.
+func (s BalanceService) Balances(ctx context.Context, addresses []string, query CoinQuery) ([]Balance, error) {
+	puzzleHashes, err := s.ResolveAddresses(addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.CoinRecords(ctx, puzzleHashes, query)
+	if err != nil {
+		return nil, err
+	}
+
+	byPuzzleHash := map[PuzzleHash][]CoinRecord{}
+	for _, record := range records {
+		byPuzzleHash[record.Coin.PuzzleHash] = append(byPuzzleHash[record.Coin.PuzzleHash], record)
+	}
+
+	balances := make([]Balance, 0, len(addresses))
+	for i, address := range addresses {
+		balance := Balance{Address: address, PuzzleHash: puzzleHashes[i], Coins: byPuzzleHash[puzzleHashes[i]]}
+		for _, record := range balance.Coins {
+			balance.Confirmed += record.Coin.Amount
+			if !record.Spent {
+				balance.Unspent += record.Coin.Amount
+			}
+		}
+		balances = append(balances, balance)
+	}
+
+	return balances, nil
+}
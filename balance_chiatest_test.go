@@ -0,0 +1,55 @@
+package marmotcoreclient_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	marmotcoreclient "github.com/freddiecoleman/marmotcore-client"
+	"github.com/freddiecoleman/marmotcore-client/chiatest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBalances(t *testing.T) {
+	first, _ := marmotcoreclient.ParsePuzzleHash(strings.Repeat("aa", 32))
+	second, _ := marmotcoreclient.ParsePuzzleHash(strings.Repeat("bb", 32))
+	secondAddress, _ := marmotcoreclient.EncodeAddress(second, "txch")
+
+	node, err := chiatest.NewNode("chia-node", "testnet")
+	assert.NoError(t, err)
+	defer node.Close()
+	node.Chain.AddCoins(
+		marmotcoreclient.CoinRecord{Coin: marmotcoreclient.Coin{ParentCoinInfo: marmotcoreclient.Bytes32{1}, PuzzleHash: first, Amount: 1000}, ConfirmedBlockIndex: 10},
+		marmotcoreclient.CoinRecord{Coin: marmotcoreclient.Coin{ParentCoinInfo: marmotcoreclient.Bytes32{2}, PuzzleHash: first, Amount: 500}, ConfirmedBlockIndex: 11, SpentBlockIndex: 12, Spent: true},
+		marmotcoreclient.CoinRecord{Coin: marmotcoreclient.Coin{ParentCoinInfo: marmotcoreclient.Bytes32{3}, PuzzleHash: second, Amount: 7}, ConfirmedBlockIndex: 13},
+	)
+
+	api := chiatest.NewAPI(node)
+	defer api.Close()
+
+	service := marmotcoreclient.BalanceService{
+		Client:  api.Client(),
+		RPC:     node.RPCOptions(),
+		Network: "testnet11",
+	}
+
+	balances, err := service.Balances(context.Background(), []string{first.String(), secondAddress}, marmotcoreclient.CoinQuery{IncludeSpent: true})
+
+	assert.NoError(t, err)
+	assert.Len(t, balances, 2)
+	assert.Equal(t, uint64(1500), balances[0].Confirmed)
+	assert.Equal(t, uint64(1000), balances[0].Unspent)
+	assert.Len(t, balances[0].Coins, 2)
+	assert.Equal(t, secondAddress, balances[1].Address)
+	assert.Equal(t, second, balances[1].PuzzleHash)
+	assert.Equal(t, uint64(7), balances[1].Unspent)
+
+	mainnetAddress, _ := marmotcoreclient.EncodeAddress(second, "xch")
+	_, err = service.Balances(context.Background(), []string{mainnetAddress}, marmotcoreclient.CoinQuery{})
+	assert.True(t, errors.Is(err, marmotcoreclient.ErrInvalidAddress))
+
+	service.Network = "mainnet"
+	_, err = service.Balances(context.Background(), []string{mainnetAddress}, marmotcoreclient.CoinQuery{})
+	assert.True(t, errors.Is(err, marmotcoreclient.ErrNoRunningNode))
+}
@@ -0,0 +1,56 @@
+package marmotcoreclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveAddressesUsesNodeNetwork(t *testing.T) {
+	puzzleHash, _ := ParsePuzzleHash(strings.Repeat("bb", 32))
+	mainnetAddress, _ := EncodeAddress(puzzleHash, "xch")
+	testnetAddress, _ := EncodeAddress(puzzleHash, "txch")
+
+	node := *newNode("testUserId", 1648394251715, "mainnet-node", "127.0.0.1", "us-west-2", "node.small", "1.3.*", "mainnet", "R", false)
+	mockNodesAndKeys(t, []Node{node}, nil)
+
+	service := BalanceService{
+		Client: MarmotcoreClient{Protocol: "http", Host: "localhost", Port: "3000", ApiVersion: "v1"},
+		NodeId: "mainnet-node",
+	}
+
+	puzzleHashes, err := service.ResolveAddresses([]string{mainnetAddress})
+	assert.NoError(t, err)
+	assert.Equal(t, []PuzzleHash{puzzleHash}, puzzleHashes)
+
+	_, err = service.ResolveAddresses([]string{testnetAddress})
+	assert.True(t, errors.Is(err, ErrInvalidAddress))
+
+	service.NodeId = "missing"
+	_, err = service.ResolveAddresses([]string{mainnetAddress})
+	assert.True(t, errors.Is(err, ErrNodeNotFound))
+}
+
+func TestBalanceServiceRequiresNetwork(t *testing.T) {
+	node := *newNode("testUserId", 1648394251715, "chia-node", "127.0.0.1", "us-west-2", "node.small", "1.3.*", "testnet10", "R", false)
+	mockNodesAndKeys(t, []Node{node}, nil)
+
+	service := BalanceService{Client: MarmotcoreClient{Protocol: "http", Host: "localhost", Port: "3000", ApiVersion: "v1"}}
+	_, err := service.CoinRecords(context.Background(), nil, CoinQuery{})
+	assert.ErrorIs(t, err, ErrNetworkRequired)
+}
+
+func TestRunningNodesChecksStatus(t *testing.T) {
+	GetFunc = func(url string) (*http.Response, error) {
+		return jsonResponse(500, `{"error":"internal"}`, nil), nil
+	}
+	Client = &MockClient{}
+
+	mc := MarmotcoreClient{Protocol: "http", Host: "localhost", Port: "3000", ApiVersion: "v1"}
+	_, err := mc.RunningNodes("testnet10")
+	assert.EqualError(t, err, "listing nodes: status 500")
+}
@@ -0,0 +1,213 @@
+package marmotcoreclient
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	bech32mConst  = 0x2bc830a3
+)
+
+var ErrInvalidAddress = errors.New("invalid address")
+
+// AddressPrefix is the address prefix for a Node.Network. Unknown networks
+// are an error rather than a guess.
+func AddressPrefix(network string) (string, error) {
+	known, err := LookupNetwork(network)
+	if err != nil {
+		return "", err
+	}
+	return known.AddressPrefix, nil
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+	chk := uint32(1)
+	for _, value := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(value)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HrpExpand(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+func bech32mChecksum(hrp string, data []byte) []byte {
+	values := append(bech32HrpExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	polymod := bech32Polymod(values) ^ bech32mConst
+
+	checksum := make([]byte, 6)
+	for i := range checksum {
+		checksum[i] = byte(polymod>>uint(5*(5-i))) & 31
+	}
+	return checksum
+}
+
+// Bech32mEncode encodes 5-bit groups of data with the human readable part.
+func Bech32mEncode(hrp string, data []byte) (string, error) {
+	for _, value := range data {
+		if value > 31 {
+			return "", fmt.Errorf("%w: data value %d is not 5 bits", ErrInvalidAddress, value)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(hrp)
+	b.WriteByte('1')
+	for _, value := range append(append([]byte(nil), data...), bech32mChecksum(hrp, data)...) {
+		b.WriteByte(bech32Charset[value])
+	}
+	return b.String(), nil
+}
+
+// Bech32mDecode returns the human readable part and the 5-bit data groups
+// of a bech32m string, after checking its checksum.
+func Bech32mDecode(s string) (string, []byte, error) {
+	if len(s) > 90 {
+		return "", nil, fmt.Errorf("%w: too long", ErrInvalidAddress)
+	}
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, fmt.Errorf("%w: mixed case", ErrInvalidAddress)
+	}
+	s = strings.ToLower(s)
+
+	separator := strings.LastIndexByte(s, '1')
+	if separator < 1 || separator+7 > len(s) {
+		return "", nil, fmt.Errorf("%w: bad separator position", ErrInvalidAddress)
+	}
+
+	hrp := s[:separator]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, fmt.Errorf("%w: bad prefix character", ErrInvalidAddress)
+		}
+	}
+
+	data := make([]byte, 0, len(s)-separator-1)
+	for _, c := range s[separator+1:] {
+		value := strings.IndexRune(bech32Charset, c)
+		if value < 0 {
+			return "", nil, fmt.Errorf("%w: bad character %q", ErrInvalidAddress, c)
+		}
+		data = append(data, byte(value))
+	}
+
+	if bech32Polymod(append(bech32HrpExpand(hrp), data...)) != bech32mConst {
+		return "", nil, fmt.Errorf("%w: bad checksum", ErrInvalidAddress)
+	}
+
+	return hrp, data[:len(data)-6], nil
+}
+
+func convertBits(data []byte, from uint, to uint, pad bool) ([]byte, error) {
+	var converted []byte
+	var acc uint32
+	var bits uint
+	maxValue := uint32(1)<<to - 1
+
+	for _, value := range data {
+		if uint32(value)>>from != 0 {
+			return nil, fmt.Errorf("%w: value out of range", ErrInvalidAddress)
+		}
+		acc = acc<<from | uint32(value)
+		bits += from
+		for bits >= to {
+			bits -= to
+			converted = append(converted, byte(acc>>bits&maxValue))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			converted = append(converted, byte(acc<<(to-bits)&maxValue))
+		}
+	} else if bits >= from || acc<<(to-bits)&maxValue != 0 {
+		return nil, fmt.Errorf("%w: bad padding", ErrInvalidAddress)
+	}
+
+	return converted, nil
+}
+
+// EncodeAddress encodes a puzzle hash as an address with the given prefix,
+// e.g. AddressPrefix(node.Network).
+func EncodeAddress(puzzleHash PuzzleHash, prefix string) (string, error) {
+	data, err := convertBits(puzzleHash[:], 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	return Bech32mEncode(prefix, data)
+}
+
+// DecodeAddress returns the prefix and puzzle hash of an address.
+func DecodeAddress(address string) (string, PuzzleHash, error) {
+	var puzzleHash PuzzleHash
+
+	prefix, data, err := Bech32mDecode(address)
+	if err != nil {
+		return "", puzzleHash, err
+	}
+
+	decoded, err := convertBits(data, 5, 8, false)
+	if err != nil {
+		return "", puzzleHash, err
+	}
+	if len(decoded) != len(puzzleHash) {
+		return "", puzzleHash, fmt.Errorf("%w: expected 32 bytes, got %d", ErrInvalidAddress, len(decoded))
+	}
+
+	copy(puzzleHash[:], decoded)
+	return prefix, puzzleHash, nil
+}
+
+// DecodeAddressForNetwork decodes an address and checks its prefix is the
+// one used on network.
+func DecodeAddressForNetwork(address string, network string) (PuzzleHash, error) {
+	prefix, puzzleHash, err := DecodeAddress(address)
+	if err != nil {
+		return puzzleHash, err
+	}
+
+	expected, err := AddressPrefix(network)
+	if err != nil {
+		return puzzleHash, err
+	}
+	if prefix != expected {
+		return puzzleHash, fmt.Errorf("%w: %s address on %s, expected %s", ErrInvalidAddress, prefix, network, expected)
+	}
+
+	return puzzleHash, nil
+}
+
+// EncodeAddress encodes a puzzle hash with the prefix for the node's network.
+func (n Node) EncodeAddress(puzzleHash PuzzleHash) (string, error) {
+	prefix, err := AddressPrefix(n.Network)
+	if err != nil {
+		return "", err
+	}
+	return EncodeAddress(puzzleHash, prefix)
+}
+
+// DecodeAddress decodes an address, which must be for the node's network.
+func (n Node) DecodeAddress(address string) (PuzzleHash, error) {
+	return DecodeAddressForNetwork(address, n.Network)
+}
@@ -0,0 +1,74 @@
+package marmotcoreclient
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBech32mVectors(t *testing.T) {
+	for _, valid := range []string{
+		"A1LQFN3A",
+		"a1lqfn3a",
+		"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx",
+		"split1checkupstagehandshakeupstreamerranterredcaperredlc445v",
+		"?1v759aa",
+	} {
+		hrp, data, err := Bech32mDecode(valid)
+		assert.NoError(t, err, valid)
+
+		encoded, err := Bech32mEncode(hrp, data)
+		assert.NoError(t, err)
+		assert.Equal(t, strings.ToLower(valid), encoded)
+	}
+
+	for _, invalid := range []string{
+		"a1lqfn3b",
+		"A1lqfn3a",
+		"1qqqqqq",
+		"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+	} {
+		_, _, err := Bech32mDecode(invalid)
+		assert.True(t, errors.Is(err, ErrInvalidAddress), invalid)
+	}
+}
+
+func TestAddressRoundTrip(t *testing.T) {
+	puzzleHash, err := ParsePuzzleHash("0x" + strings.Repeat("5a", 32))
+	assert.NoError(t, err)
+
+	mainnet, err := EncodeAddress(puzzleHash, "xch")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(mainnet, "xch1"))
+	assert.Len(t, mainnet, 62)
+
+	testnet, err := EncodeAddress(puzzleHash, "txch")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(testnet, "txch1"))
+
+	prefix, decoded, err := DecodeAddress(testnet)
+	assert.NoError(t, err)
+	assert.Equal(t, "txch", prefix)
+	assert.Equal(t, puzzleHash, decoded)
+
+	decoded, err = ParsePuzzleHash(mainnet)
+	assert.NoError(t, err)
+	assert.Equal(t, puzzleHash, decoded)
+
+	_, err = DecodeAddressForNetwork(mainnet, "testnet10")
+	assert.EqualError(t, err, "invalid address: xch address on testnet10, expected txch")
+
+	decoded, err = DecodeAddressForNetwork(mainnet, "mainnet")
+	assert.NoError(t, err)
+	assert.Equal(t, puzzleHash, decoded)
+
+	node := newNode("testUserId", 1648394251715, "testNodeId", "54.71.136.33", "us-west-2", "node.small", "1.3.*", "testnet", "R", false)
+	address, err := node.EncodeAddress(puzzleHash)
+	assert.NoError(t, err)
+	assert.Equal(t, testnet, address)
+
+	_, err = node.DecodeAddress(mainnet)
+	assert.Error(t, err)
+}
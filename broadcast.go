@@ -0,0 +1,325 @@
+package marmotcoreclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTxRejected       = errors.New("transaction rejected by every node")
+	ErrInclusionTimeout = errors.New("transaction not included before the timeout")
+)
+
+type PushStatus string
+
+const (
+	PushSuccess          PushStatus = "SUCCESS"
+	PushPending          PushStatus = "PENDING"
+	PushAlreadyInMempool PushStatus = "ALREADY_IN_MEMPOOL"
+	PushFailed           PushStatus = "FAILED"
+)
+
+// PushResult is one node's answer to push_tx.
+type PushResult struct {
+	NodeId string     `json:"node_id"`
+	Status PushStatus `json:"status"`
+	Reason string     `json:"reason,omitempty"`
+}
+
+func (r PushResult) Accepted() bool {
+	return r.Status != PushFailed
+}
+
+// PushTx submits a spend bundle and returns the status the node reported.
+// A rejected transaction is an *RPCError.
+func (c *RPCClient) PushTx(ctx context.Context, bundle SpendBundle) (string, error) {
+	var response struct {
+		Status string `json:"status"`
+	}
+
+	err := c.Call(ctx, "push_tx", map[string]SpendBundle{"spend_bundle": bundle}, &response)
+	return response.Status, err
+}
+
+// normalisePush maps push_tx replies onto PushStatus. Nodes report a
+// transaction they already have as a failure with
+// ALREADY_INCLUDING_TRANSACTION, which is as good as accepted.
+func normalisePush(nodeId string, status string, err error) PushResult {
+	result := PushResult{NodeId: nodeId}
+
+	switch {
+	case err != nil && strings.Contains(err.Error(), "ALREADY_INCLUDING_TRANSACTION"):
+		result.Status = PushAlreadyInMempool
+	case err != nil:
+		result.Status = PushFailed
+		result.Reason = err.Error()
+		var rpcErr *RPCError
+		if errors.As(err, &rpcErr) {
+			result.Reason = rpcErr.Message
+		}
+	case status == string(PushSuccess) || status == string(PushPending):
+		result.Status = PushStatus(status)
+	default:
+		result.Status = PushFailed
+		result.Reason = fmt.Sprintf("unexpected status %q", status)
+	}
+
+	return result
+}
+
+type BroadcastResult struct {
+	TxId    Bytes32      `json:"tx_id"`
+	Results []PushResult `json:"results"`
+}
+
+// Accepted is true when at least one node took the transaction.
+func (r BroadcastResult) Accepted() bool {
+	for _, result := range r.Results {
+		if result.Accepted() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r BroadcastResult) reasons() string {
+	var reasons []string
+	for _, result := range r.Results {
+		reasons = append(reasons, result.NodeId+": "+result.Reason)
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// Inclusion is where a broadcast transaction landed.
+type Inclusion struct {
+	TxId         Bytes32 `json:"tx_id"`
+	Height       uint32  `json:"height"`
+	Rebroadcasts int     `json:"rebroadcasts"`
+}
+
+// Broadcaster pushes spend bundles to Nodes running nodes of Network at
+// once, or all of them when Nodes is 0, and waits for the spent coins to
+// show up on chain. A transaction that isn't included within RetryAfter is
+// pushed again, in case it dropped out of the mempools.
+type Broadcaster struct {
+	Client       MarmotcoreClient
+	RPC          RPCOptions
+	Network      string
+	Nodes        int
+	Timeout      time.Duration
+	PollInterval time.Duration
+	RetryAfter   time.Duration
+}
+
+func (b Broadcaster) clients() ([]*RPCClient, error) {
+	if b.Network == "" {
+		return nil, fmt.Errorf("broadcaster: %w", ErrNetworkRequired)
+	}
+
+	nodes, err := b.Client.RunningNodes(b.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	var clients []*RPCClient
+	for _, node := range nodes {
+		if b.Nodes > 0 && len(clients) == b.Nodes {
+			break
+		}
+		rpc, err := b.Client.RPCClientForNode(node, b.RPC)
+		if err != nil {
+			continue
+		}
+		clients = append(clients, rpc)
+	}
+
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("%w on %s", ErrNoRunningNode, b.Network)
+	}
+	return clients, nil
+}
+
+// closeEach closes every client in clients.
+func closeEach(clients []*RPCClient) {
+	for _, rpc := range clients {
+		rpc.Close()
+	}
+}
+
+// Broadcast pushes the bundle to the nodes in parallel. It returns
+// ErrTxRejected, along with the per-node results, when no node accepted it.
+func (b Broadcaster) Broadcast(ctx context.Context, bundle SpendBundle) (BroadcastResult, error) {
+	var result BroadcastResult
+
+	txId, err := bundle.Name()
+	if err != nil {
+		return result, err
+	}
+	result.TxId = txId
+
+	clients, err := b.clients()
+	if err != nil {
+		return result, err
+	}
+	defer closeEach(clients)
+
+	return push(ctx, clients, txId, bundle)
+}
+
+// push sends the bundle to every client at once.
+func push(ctx context.Context, clients []*RPCClient, txId Bytes32, bundle SpendBundle) (BroadcastResult, error) {
+	result := BroadcastResult{TxId: txId}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, rpc := range clients {
+		wg.Add(1)
+		go func(rpc *RPCClient) {
+			defer wg.Done()
+			status, err := rpc.PushTx(ctx, bundle)
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.Results = append(result.Results, normalisePush(rpc.Node.NodeId, status, err))
+		}(rpc)
+	}
+	wg.Wait()
+
+	sort.Slice(result.Results, func(i, j int) bool { return result.Results[i].NodeId < result.Results[j].NodeId })
+
+	if !result.Accepted() {
+		return result, fmt.Errorf("%w: %s", ErrTxRejected, result.reasons())
+	}
+	return result, nil
+}
+
+// included reports the height at which every coin the bundle spends was
+// spent, asking each node in turn until one answers.
+func included(ctx context.Context, clients []*RPCClient, removals []Bytes32) (uint32, bool, error) {
+	var lastErr error
+	for _, rpc := range clients {
+		records, err := rpc.GetCoinRecordsByNames(ctx, removals, CoinQuery{IncludeSpent: true})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		var height uint32
+		spent := 0
+		for _, record := range records {
+			if record.Spent {
+				spent++
+				if record.SpentBlockIndex > height {
+					height = record.SpentBlockIndex
+				}
+			}
+		}
+		return height, spent == len(removals), nil
+	}
+
+	return 0, false, lastErr
+}
+
+// WaitForInclusion polls until every coin the bundle spends is spent,
+// rebroadcasting every RetryAfter, until Timeout. The nodes to ask are
+// picked once, when it starts.
+func (b Broadcaster) WaitForInclusion(ctx context.Context, bundle SpendBundle) (Inclusion, error) {
+	txId, err := bundle.Name()
+	if err != nil {
+		return Inclusion{}, err
+	}
+
+	clients, err := b.clients()
+	if err != nil {
+		return Inclusion{TxId: txId}, err
+	}
+	defer closeEach(clients)
+
+	return b.waitForInclusion(ctx, clients, txId, bundle)
+}
+
+// waitForInclusion is WaitForInclusion on nodes that are already picked.
+func (b Broadcaster) waitForInclusion(ctx context.Context, clients []*RPCClient, txId Bytes32, bundle SpendBundle) (Inclusion, error) {
+	inclusion := Inclusion{TxId: txId}
+
+	timeout := b.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Minute
+	}
+	pollInterval := b.PollInterval
+	if pollInterval == 0 {
+		pollInterval = 10 * time.Second
+	}
+	retryAfter := b.RetryAfter
+	if retryAfter == 0 {
+		retryAfter = 2 * time.Minute
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	removals := bundle.Removals()
+	lastPush := time.Now()
+
+	for {
+		height, done, err := included(ctx, clients, removals)
+		if err == nil && done {
+			inclusion.Height = height
+			return inclusion, nil
+		}
+
+		if time.Since(lastPush) >= retryAfter {
+			lastPush = time.Now()
+			inclusion.Rebroadcasts++
+
+			if _, err := push(ctx, clients, txId, bundle); err != nil && ctx.Err() == nil {
+				// The coins may have been spent since the last poll.
+				if height, done, checkErr := included(ctx, clients, removals); checkErr == nil && done {
+					inclusion.Height = height
+					return inclusion, nil
+				}
+				return inclusion, err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return inclusion, fmt.Errorf("%w: %s after %s", ErrInclusionTimeout, txId, timeout)
+			}
+			return inclusion, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Send broadcasts the bundle and waits for it to be included, asking the
+// same nodes throughout.
+func (b Broadcaster) Send(ctx context.Context, bundle SpendBundle) (BroadcastResult, Inclusion, error) {
+	txId, err := bundle.Name()
+	if err != nil {
+		return BroadcastResult{}, Inclusion{}, err
+	}
+
+	clients, err := b.clients()
+	if err != nil {
+		return BroadcastResult{TxId: txId}, Inclusion{}, err
+	}
+	defer closeEach(clients)
+
+	result, err := push(ctx, clients, txId, bundle)
+	if err != nil {
+		return result, Inclusion{}, err
+	}
+
+	inclusion, err := b.waitForInclusion(ctx, clients, txId, bundle)
+	return result, inclusion, err
+}
@@ -0,0 +1,88 @@
+package marmotcoreclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	marmotcoreclient "github.com/freddiecoleman/marmotcore-client"
+	"github.com/freddiecoleman/marmotcore-client/chiatest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroadcaster(t *testing.T) {
+	node, err := chiatest.NewNode("chia-node", "testnet")
+	assert.NoError(t, err)
+	defer node.Close()
+	node.Chain.Generate(42)
+
+	var mu sync.Mutex
+	pushes := 0
+	node.Handle("push_tx", func(request json.RawMessage) (map[string]interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		pushes++
+		if pushes > 1 {
+			// Dropped from the mempool and pushed again; it lands this time.
+			var params struct {
+				SpendBundle marmotcoreclient.SpendBundle `json:"spend_bundle"`
+			}
+			json.Unmarshal(request, &params)
+			node.Mempool.Add(params.SpendBundle, 0, 0)
+			node.Farm()
+		}
+		return map[string]interface{}{"status": "SUCCESS"}, nil
+	})
+
+	api := chiatest.NewAPI(node)
+	defer api.Close()
+
+	broadcaster := marmotcoreclient.Broadcaster{
+		Client:       api.Client(),
+		RPC:          node.RPCOptions(),
+		Network:      "testnet",
+		PollInterval: time.Millisecond,
+		RetryAfter:   5 * time.Millisecond,
+	}
+
+	result, inclusion, err := broadcaster.Send(context.Background(), marmotcoreclient.SpendBundleForTest())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []marmotcoreclient.PushResult{{NodeId: "chia-node", Status: marmotcoreclient.PushSuccess}}, result.Results)
+	assert.Equal(t, uint32(42), inclusion.Height)
+	assert.Equal(t, 1, inclusion.Rebroadcasts)
+	assert.Equal(t, result.TxId, inclusion.TxId)
+}
+
+func TestBroadcasterRejectedAndTimeout(t *testing.T) {
+	node, err := chiatest.NewNode("chia-node", "testnet")
+	assert.NoError(t, err)
+	defer node.Close()
+	node.Handle("push_tx", func(request json.RawMessage) (map[string]interface{}, error) {
+		return nil, errors.New("Failed to include transaction 0x01, error DOUBLE_SPEND")
+	})
+
+	api := chiatest.NewAPI(node)
+	defer api.Close()
+
+	broadcaster := marmotcoreclient.Broadcaster{
+		Client:       api.Client(),
+		RPC:          node.RPCOptions(),
+		Network:      "testnet",
+		Timeout:      20 * time.Millisecond,
+		PollInterval: time.Millisecond,
+		RetryAfter:   time.Hour,
+	}
+
+	result, err := broadcaster.Broadcast(context.Background(), marmotcoreclient.SpendBundleForTest())
+	assert.True(t, errors.Is(err, marmotcoreclient.ErrTxRejected))
+	assert.False(t, result.Accepted())
+	assert.EqualError(t, err, "transaction rejected by every node: chia-node: Failed to include transaction 0x01, error DOUBLE_SPEND")
+
+	_, err = broadcaster.WaitForInclusion(context.Background(), marmotcoreclient.SpendBundleForTest())
+	assert.True(t, errors.Is(err, marmotcoreclient.ErrInclusionTimeout))
+}
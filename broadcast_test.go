@@ -0,0 +1,82 @@
+package marmotcoreclient
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testSpendBundle() SpendBundle {
+	return SpendBundle{
+		CoinSpends: []CoinSpend{{
+			Coin:         Coin{ParentCoinInfo: Bytes32{1}, PuzzleHash: PuzzleHash{2}, Amount: 1000},
+			PuzzleReveal: "0xff0180",
+			Solution:     "0x80",
+		}},
+		AggregatedSignature: "0xc0" + strings.Repeat("00", 95),
+	}
+}
+
+// SpendBundleForTest shares testSpendBundle with the external tests.
+var SpendBundleForTest = testSpendBundle
+
+func TestNormalisePush(t *testing.T) {
+	assert.Equal(t, PushResult{NodeId: "a", Status: PushSuccess}, normalisePush("a", "SUCCESS", nil))
+	assert.Equal(t, PushResult{NodeId: "a", Status: PushPending}, normalisePush("a", "PENDING", nil))
+	assert.Equal(t, PushResult{NodeId: "a", Status: PushAlreadyInMempool},
+		normalisePush("a", "", &RPCError{Message: "Failed to include transaction 0x01, error ALREADY_INCLUDING_TRANSACTION"}))
+	assert.Equal(t, PushResult{NodeId: "a", Status: PushFailed, Reason: "Failed to include transaction 0x01, error DOUBLE_SPEND"},
+		normalisePush("a", "", &RPCError{Message: "Failed to include transaction 0x01, error DOUBLE_SPEND"}))
+	assert.Equal(t, PushResult{NodeId: "a", Status: PushFailed, Reason: `unexpected status "FAILED"`}, normalisePush("a", "FAILED", nil))
+}
+
+func TestBroadcasterRequiresNetwork(t *testing.T) {
+	node := *newNode("testUserId", 1648394251715, "chia-node", "127.0.0.1", "us-west-2", "node.small", "1.3.*", "testnet10", "R", false)
+	mockNodesAndKeys(t, []Node{node}, nil)
+
+	broadcaster := Broadcaster{Client: MarmotcoreClient{Protocol: "http", Host: "localhost", Port: "3000", ApiVersion: "v1"}}
+	_, err := broadcaster.Broadcast(context.Background(), testSpendBundle())
+	assert.ErrorIs(t, err, ErrNetworkRequired)
+}
+
+func TestBroadcasterListsNodesOnce(t *testing.T) {
+	_, port := newTestRPCServer(t, map[string]string{
+		"push_tx":                   `{"success":true,"status":"SUCCESS"}`,
+		"get_coin_records_by_names": `{"success":true,"coin_records":[]}`,
+	})
+
+	node := *newNode("testUserId", 1648394251715, "chia-node", "127.0.0.1", "us-west-2", "node.small", "1.3.*", "testnet", "R", false)
+	mockNodeAndKey(t, node, newTestKeyPair(t, "chia-node", time.Now().Add(-time.Hour), time.Now().Add(time.Hour)))
+
+	listed := 0
+	get := GetFunc
+	GetFunc = func(url string) (*http.Response, error) {
+		if strings.HasSuffix(url, "/nodes") {
+			listed++
+		}
+		return get(url)
+	}
+
+	broadcaster := Broadcaster{
+		Client:       MarmotcoreClient{Protocol: "http", Host: "localhost", Port: "3000", ApiVersion: "v1"},
+		RPC:          RPCOptions{Port: port, InsecureSkipVerify: true},
+		Network:      "testnet",
+		Timeout:      50 * time.Millisecond,
+		PollInterval: time.Millisecond,
+		RetryAfter:   5 * time.Millisecond,
+	}
+
+	inclusion, err := broadcaster.WaitForInclusion(context.Background(), testSpendBundle())
+	assert.ErrorIs(t, err, ErrInclusionTimeout)
+	assert.Greater(t, inclusion.Rebroadcasts, 0)
+	assert.Equal(t, 1, listed)
+
+	listed = 0
+	_, _, err = broadcaster.Send(context.Background(), testSpendBundle())
+	assert.ErrorIs(t, err, ErrInclusionTimeout)
+	assert.Equal(t, 1, listed)
+}
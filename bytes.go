@@ -0,0 +1,120 @@
+package marmotcoreclient
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Bytes32 is a 32 byte hash such as a header hash or coin ID. It encodes
+// as 0x-prefixed hex, the way the Chia RPC API returns it.
+type Bytes32 [32]byte
+
+// PuzzleHash is the hash of a puzzle, which an address encodes.
+type PuzzleHash Bytes32
+
+func parseHex32(s string) ([32]byte, error) {
+	var b [32]byte
+
+	decoded, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"))
+	if err != nil {
+		return b, fmt.Errorf("invalid hex %q: %w", s, err)
+	}
+	if len(decoded) != len(b) {
+		return b, fmt.Errorf("expected 32 bytes, got %d in %q", len(decoded), s)
+	}
+
+	copy(b[:], decoded)
+	return b, nil
+}
+
+func unmarshalHex32(data []byte, b *[32]byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	parsed, err := parseHex32(s)
+	if err != nil {
+		return err
+	}
+
+	*b = parsed
+	return nil
+}
+
+func ParseBytes32(s string) (Bytes32, error) {
+	b, err := parseHex32(s)
+	return Bytes32(b), err
+}
+
+func (b Bytes32) String() string {
+	return "0x" + hex.EncodeToString(b[:])
+}
+
+func (b Bytes32) IsZero() bool {
+	return b == Bytes32{}
+}
+
+func (b Bytes32) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+func (b *Bytes32) UnmarshalText(text []byte) error {
+	parsed, err := ParseBytes32(string(text))
+	if err != nil {
+		return err
+	}
+	*b = parsed
+	return nil
+}
+
+func (b Bytes32) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.String())
+}
+
+func (b *Bytes32) UnmarshalJSON(data []byte) error {
+	return unmarshalHex32(data, (*[32]byte)(b))
+}
+
+// ParsePuzzleHash accepts either hex, with or without 0x, or an xch/txch
+// address, so user input in either form can be passed to the RPC API.
+func ParsePuzzleHash(s string) (PuzzleHash, error) {
+	if strings.HasPrefix(s, "xch1") || strings.HasPrefix(s, "txch1") {
+		_, puzzleHash, err := DecodeAddress(s)
+		return puzzleHash, err
+	}
+
+	b, err := parseHex32(s)
+	return PuzzleHash(b), err
+}
+
+func (p PuzzleHash) String() string {
+	return Bytes32(p).String()
+}
+
+func (p PuzzleHash) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *PuzzleHash) UnmarshalText(text []byte) error {
+	parsed, err := ParsePuzzleHash(string(text))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+func (p PuzzleHash) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+func (p *PuzzleHash) UnmarshalJSON(data []byte) error {
+	return unmarshalHex32(data, (*[32]byte)(p))
+}
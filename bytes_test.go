@@ -0,0 +1,36 @@
+package marmotcoreclient
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBytes32JSON(t *testing.T) {
+	hash := "0x" + strings.Repeat("ab", 32)
+
+	var block BlockRecord
+	err := json.Unmarshal([]byte(`{"header_hash":"`+hash+`","prev_hash":null,"farmer_puzzle_hash":"`+strings.Repeat("cd", 32)+`"}`), &block)
+	assert.NoError(t, err)
+	assert.Equal(t, hash, block.HeaderHash.String())
+	assert.True(t, block.PrevHash.IsZero())
+	assert.Equal(t, "0x"+strings.Repeat("cd", 32), block.FarmerPuzzleHash.String())
+
+	encoded, err := json.Marshal(map[string]interface{}{"hash": block.HeaderHash, "puzzle_hash": block.FarmerPuzzleHash})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"hash":"`+hash+`","puzzle_hash":"0x`+strings.Repeat("cd", 32)+`"}`, string(encoded))
+}
+
+func TestParseBytes32(t *testing.T) {
+	_, err := ParseBytes32("0xabcd")
+	assert.EqualError(t, err, `expected 32 bytes, got 2 in "0xabcd"`)
+
+	_, err = ParseBytes32("0xzz")
+	assert.Error(t, err)
+
+	parsed, err := ParseBytes32(strings.Repeat("01", 32))
+	assert.NoError(t, err)
+	assert.Equal(t, byte(1), parsed[31])
+}
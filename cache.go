@@ -0,0 +1,288 @@
+package marmotcoreclient
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type CacheEntry struct {
+	StatusCode int       `json:"status_code"`
+	Body       []byte    `json:"body"`
+	ETag       string    `json:"etag,omitempty"`
+	Expires    time.Time `json:"expires"`
+}
+
+// CacheBackend stores cache entries by request URL. Entries are kept past
+// their expiry so they can be revalidated with If-None-Match.
+type CacheBackend interface {
+	Get(key string) (CacheEntry, bool)
+	Set(key string, entry CacheEntry)
+	Delete(key string)
+}
+
+// ResponseCache caches GetNode, GetKey and GetKeys responses. A zero TTL
+// disables caching for that resource, NotFoundTTL controls how long 404s
+// are remembered. DeleteNode invalidates the node, its key and the key list.
+// A FileCache backend only caches keys if it has IncludeKeys set.
+type ResponseCache struct {
+	Backend     CacheBackend
+	NodeTTL     time.Duration
+	KeyTTL      time.Duration
+	KeysTTL     time.Duration
+	NotFoundTTL time.Duration
+}
+
+var Cache *ResponseCache
+
+func (c *ResponseCache) ttl(operation string) time.Duration {
+	if c == nil || c.Backend == nil {
+		return 0
+	}
+
+	// Key responses hold private keys, which a FileCache would write to
+	// disk as they are.
+	if fileCache, ok := c.Backend.(*FileCache); ok && !fileCache.IncludeKeys && operation != "GetNode" {
+		return 0
+	}
+
+	switch operation {
+	case "GetNode":
+		return c.NodeTTL
+	case "GetKey":
+		return c.KeyTTL
+	case "GetKeys":
+		return c.KeysTTL
+	}
+
+	return 0
+}
+
+func (c *ResponseCache) invalidateNode(baseUrl string, nodeId string) {
+	if c == nil || c.Backend == nil {
+		return
+	}
+
+	c.Backend.Delete(baseUrl + "/nodes/" + nodeId)
+	c.Backend.Delete(baseUrl + "/keys/" + nodeId)
+	c.Backend.Delete(baseUrl + "/keys")
+}
+
+func (mc MarmotcoreClient) conditionalGetRequest(path string, etag string) (resp *http.Response, err error) {
+	req, err := http.NewRequest("GET", mc.url()+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("If-None-Match", etag)
+	return mc.httpClient().Do(req)
+}
+
+func (mc MarmotcoreClient) cachedGet(operation string, nodeId string, path string) ([]byte, error) {
+	cache := Cache
+	ttl := cache.ttl(operation)
+
+	if ttl <= 0 {
+		return mc.call(operation, nodeId, 0, func() (*http.Response, error) {
+			return mc.getRequest(path)
+		})
+	}
+
+	key := mc.url() + path
+	entry, cached := cache.Backend.Get(key)
+	now := time.Now()
+
+	if cached && now.Before(entry.Expires) {
+		end := startCall(Call{Operation: "marmotcore." + operation, NodeId: nodeId})
+		end(CallResult{StatusCode: entry.StatusCode, Cached: true, BytesReceived: len(entry.Body)})
+		return entry.Body, nil
+	}
+
+	var statusCode int
+	var etag string
+
+	body, err := mc.call(operation, nodeId, 0, func() (*http.Response, error) {
+		var resp *http.Response
+		var err error
+
+		if cached && entry.ETag != "" {
+			resp, err = mc.conditionalGetRequest(path, entry.ETag)
+		} else {
+			resp, err = mc.getRequest(path)
+		}
+
+		if resp != nil {
+			statusCode = resp.StatusCode
+			etag = resp.Header.Get("ETag")
+		}
+
+		return resp, err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case statusCode == http.StatusNotModified && cached:
+		body = entry.Body
+		statusCode = entry.StatusCode
+		if etag == "" {
+			etag = entry.ETag
+		}
+	case statusCode == http.StatusNotFound:
+		ttl = cache.NotFoundTTL
+	case statusCode != http.StatusOK:
+		return body, nil
+	}
+
+	if ttl > 0 {
+		cache.Backend.Set(key, CacheEntry{StatusCode: statusCode, Body: body, ETag: etag, Expires: now.Add(ttl)})
+	}
+
+	return body, nil
+}
+
+type lruItem struct {
+	key   string
+	entry CacheEntry
+}
+
+// LRUCache is an in-memory CacheBackend that evicts the least recently used
+// entry once it holds more than the size given to NewLRUCache.
+type LRUCache struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func NewLRUCache(size int) *LRUCache {
+	return &LRUCache{
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (c *LRUCache) Get(key string) (CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return CacheEntry{}, false
+	}
+
+	c.order.MoveToFront(element)
+	return element.Value.(*lruItem).entry, true
+}
+
+func (c *LRUCache) Set(key string, entry CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*lruItem).entry = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruItem{key: key, entry: entry})
+
+	for c.size > 0 && c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruItem).key)
+	}
+}
+
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+}
+
+// FileCache is a CacheBackend that keeps one file per entry in Dir, so
+// separate processes can share results. GetKey and GetKeys responses
+// contain private keys and are only cached with IncludeKeys, in which case
+// they are stored unencrypted in files only the owner can read.
+type FileCache struct {
+	Dir         string
+	IncludeKeys bool
+}
+
+func NewFileCache(dir string) *FileCache {
+	return &FileCache{Dir: dir}
+}
+
+// DefaultCacheDir is marmotcore under the user's cache directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "marmotcore"), nil
+}
+
+func (c *FileCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *FileCache) Get(key string) (CacheEntry, bool) {
+	var entry CacheEntry
+
+	data, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		return entry, false
+	}
+
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, false
+	}
+
+	return entry, true
+}
+
+func (c *FileCache) Set(key string, entry CacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		return
+	}
+
+	tmp, err := ioutil.TempFile(c.Dir, ".entry-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return
+	}
+
+	os.Rename(tmp.Name(), c.path(key))
+}
+
+func (c *FileCache) Delete(key string) {
+	os.Remove(c.path(key))
+}
@@ -0,0 +1,164 @@
+package marmotcoreclient
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCachingClient(t *testing.T, cache *ResponseCache) MarmotcoreClient {
+	Cache = cache
+	t.Cleanup(func() { Cache = nil })
+	Client = &MockClient{}
+
+	return MarmotcoreClient{Protocol: "http", Host: "localhost", Port: "3000", ApiVersion: "v1"}
+}
+
+func jsonResponse(statusCode int, body string, header http.Header) *http.Response {
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     header,
+		Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+	}
+}
+
+func TestCacheServesFreshEntries(t *testing.T) {
+	mc := newCachingClient(t, &ResponseCache{Backend: NewLRUCache(10), KeyTTL: time.Hour})
+
+	requests := 0
+	GetFunc = func(url string) (*http.Response, error) {
+		requests++
+		return jsonResponse(200, `{"key":{"node_id":"chia-node","cert":"cert"}}`, nil), nil
+	}
+
+	first, _ := mc.GetKey("chia-node")
+	second, _ := mc.GetKey("chia-node")
+
+	assert.Equal(t, 1, requests)
+	assert.Equal(t, "cert", second.Key.Cert)
+	assert.EqualValues(t, first, second)
+}
+
+func TestCacheRemembersNotFound(t *testing.T) {
+	mc := newCachingClient(t, &ResponseCache{Backend: NewLRUCache(10), NodeTTL: time.Hour, NotFoundTTL: time.Minute})
+
+	requests := 0
+	GetFunc = func(url string) (*http.Response, error) {
+		requests++
+		return jsonResponse(404, `{}`, nil), nil
+	}
+
+	mc.GetNode("missing")
+	mc.GetNode("missing")
+
+	assert.Equal(t, 1, requests)
+}
+
+func TestCacheRevalidatesWithETag(t *testing.T) {
+	backend := NewLRUCache(10)
+	mc := newCachingClient(t, &ResponseCache{Backend: backend, NodeTTL: time.Hour})
+
+	backend.Set("http://localhost:3000/v1/nodes/chia-node", CacheEntry{
+		StatusCode: 200,
+		Body:       []byte(`{"node":{"node_id":"chia-node","state":"R"}}`),
+		ETag:       `"v1"`,
+		Expires:    time.Now().Add(-time.Second),
+	})
+
+	DoFunc = func(req *http.Request) (*http.Response, error) {
+		assert.Equal(t, `"v1"`, req.Header.Get("If-None-Match"))
+		return jsonResponse(304, ``, nil), nil
+	}
+
+	node, err := mc.GetNode("chia-node")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "R", node.Node.State)
+
+	entry, _ := backend.Get("http://localhost:3000/v1/nodes/chia-node")
+	assert.True(t, entry.Expires.After(time.Now()))
+	assert.Equal(t, `"v1"`, entry.ETag)
+}
+
+func TestDeleteNodeInvalidatesCache(t *testing.T) {
+	backend := NewLRUCache(10)
+	mc := newCachingClient(t, &ResponseCache{Backend: backend, NodeTTL: time.Hour, KeyTTL: time.Hour, KeysTTL: time.Hour})
+
+	for _, path := range []string{"/nodes/chia-node", "/keys/chia-node", "/keys", "/keys/other-node"} {
+		backend.Set("http://localhost:3000/v1"+path, CacheEntry{StatusCode: 200, Expires: time.Now().Add(time.Hour)})
+	}
+
+	DoFunc = func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(200, `{"deleted":true}`, nil), nil
+	}
+
+	mc.DeleteNode("chia-node")
+
+	for _, path := range []string{"/nodes/chia-node", "/keys/chia-node", "/keys"} {
+		_, ok := backend.Get("http://localhost:3000/v1" + path)
+		assert.False(t, ok, path)
+	}
+	_, ok := backend.Get("http://localhost:3000/v1/keys/other-node")
+	assert.True(t, ok)
+}
+
+func TestLRUCacheEvictsOldest(t *testing.T) {
+	cache := NewLRUCache(2)
+
+	cache.Set("a", CacheEntry{StatusCode: 200})
+	cache.Set("b", CacheEntry{StatusCode: 200})
+	cache.Get("a")
+	cache.Set("c", CacheEntry{StatusCode: 200})
+
+	_, ok := cache.Get("b")
+	assert.False(t, ok)
+	_, ok = cache.Get("a")
+	assert.True(t, ok)
+	_, ok = cache.Get("c")
+	assert.True(t, ok)
+}
+
+func TestFileCache(t *testing.T) {
+	cache := NewFileCache(t.TempDir())
+	expires := time.Now().Add(time.Hour).Round(0)
+
+	cache.Set("http://localhost:3000/v1/keys", CacheEntry{StatusCode: 200, Body: []byte(`{"keys":[]}`), ETag: `"abc"`, Expires: expires})
+
+	entry, ok := cache.Get("http://localhost:3000/v1/keys")
+	assert.True(t, ok)
+	assert.Equal(t, []byte(`{"keys":[]}`), entry.Body)
+	assert.Equal(t, `"abc"`, entry.ETag)
+	assert.True(t, expires.Equal(entry.Expires))
+
+	cache.Delete("http://localhost:3000/v1/keys")
+
+	_, ok = cache.Get("http://localhost:3000/v1/keys")
+	assert.False(t, ok)
+}
+
+func TestFileCacheLeavesOutKeys(t *testing.T) {
+	backend := NewFileCache(t.TempDir())
+	mc := newCachingClient(t, &ResponseCache{Backend: backend, NodeTTL: time.Hour, KeyTTL: time.Hour, KeysTTL: time.Hour})
+
+	requests := 0
+	GetFunc = func(url string) (*http.Response, error) {
+		requests++
+		return jsonResponse(200, `{"key":{"node_id":"chia-node","key":"private","cert":"cert"}}`, nil), nil
+	}
+
+	mc.GetKey("chia-node")
+	mc.GetKey("chia-node")
+	assert.Equal(t, 2, requests)
+
+	files, _ := ioutil.ReadDir(backend.Dir)
+	assert.Empty(t, files)
+
+	backend.IncludeKeys = true
+	mc.GetKey("chia-node")
+	mc.GetKey("chia-node")
+	assert.Equal(t, 3, requests)
+}
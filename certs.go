@@ -0,0 +1,197 @@
+package marmotcoreclient
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type CertInfo struct {
+	NodeId             string    `json:"node_id"`
+	Subject            string    `json:"subject"`
+	Issuer             string    `json:"issuer"`
+	DNSNames           []string  `json:"dns_names"`
+	IPAddresses        []string  `json:"ip_addresses"`
+	SerialNumber       string    `json:"serial_number"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	SHA1Fingerprint    string    `json:"sha1_fingerprint"`
+	SHA256Fingerprint  string    `json:"sha256_fingerprint"`
+	PublicKeyAlgorithm string    `json:"public_key_algorithm"`
+	KeySize            int       `json:"key_size"`
+}
+
+type CertFindingKind string
+
+const (
+	CertExpired     CertFindingKind = "expired"
+	CertExpiring    CertFindingKind = "expiring"
+	CertNotYetValid CertFindingKind = "not_yet_valid"
+	CertKeyMismatch CertFindingKind = "key_mismatch"
+	CertUnreadable  CertFindingKind = "unreadable"
+)
+
+type CertFinding struct {
+	NodeId  string          `json:"node_id"`
+	Kind    CertFindingKind `json:"kind"`
+	Message string          `json:"message"`
+}
+
+// ParseCert parses a certificate in the base64 PEM form used by Key.Cert.
+func ParseCert(cert string) (*x509.Certificate, error) {
+	data, err := base64.StdEncoding.DecodeString(cert)
+	if err != nil {
+		return nil, fmt.Errorf("decoding cert: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("cert is not a PEM encoded certificate")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func parsePrivateKey(key Secret) (crypto.Signer, error) {
+	var signer crypto.Signer
+
+	err := key.Use(func(data []byte) error {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return errors.New("key is not PEM encoded")
+		}
+		defer zero(block.Bytes)
+
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			rsaKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			signer = rsaKey
+			return err
+		case "EC PRIVATE KEY":
+			ecKey, err := x509.ParseECPrivateKey(block.Bytes)
+			signer = ecKey
+			return err
+		case "PRIVATE KEY":
+			parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return err
+			}
+			var ok bool
+			if signer, ok = parsed.(crypto.Signer); !ok {
+				return fmt.Errorf("unsupported private key type %T", parsed)
+			}
+			return nil
+		}
+
+		return fmt.Errorf("unsupported PEM block %q", block.Type)
+	})
+
+	return signer, err
+}
+
+func InspectKey(key Key) (CertInfo, error) {
+	info := CertInfo{NodeId: key.NodeId}
+
+	cert, err := ParseCert(key.Cert)
+	if err != nil {
+		return info, err
+	}
+
+	sha1Sum := sha1.Sum(cert.Raw)
+	sha256Sum := sha256.Sum256(cert.Raw)
+
+	info.Subject = cert.Subject.String()
+	info.Issuer = cert.Issuer.String()
+	info.DNSNames = cert.DNSNames
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	info.SerialNumber = cert.SerialNumber.Text(16)
+	info.NotBefore = cert.NotBefore
+	info.NotAfter = cert.NotAfter
+	info.SHA1Fingerprint = fingerprint(sha1Sum[:])
+	info.SHA256Fingerprint = fingerprint(sha256Sum[:])
+	info.PublicKeyAlgorithm = cert.PublicKeyAlgorithm.String()
+
+	switch publicKey := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		info.KeySize = publicKey.N.BitLen()
+	case *ecdsa.PublicKey:
+		info.KeySize = publicKey.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		info.KeySize = 256
+	}
+
+	return info, nil
+}
+
+func fingerprint(sum []byte) string {
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// KeyMatchesCert reports whether the private key belongs to the cert.
+func KeyMatchesCert(key Key) (bool, error) {
+	cert, err := ParseCert(key.Cert)
+	if err != nil {
+		return false, err
+	}
+
+	signer, err := parsePrivateKey(key.Key)
+	if err != nil {
+		return false, err
+	}
+
+	publicKey, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return false, fmt.Errorf("unsupported public key type %T", signer.Public())
+	}
+
+	return publicKey.Equal(cert.PublicKey), nil
+}
+
+// CheckCerts flags certs that are expired, not yet valid or expire within
+// threshold of now, certs that can't be parsed, and keys whose private
+// key doesn't match their cert.
+func CheckCerts(keys []Key, threshold time.Duration, now time.Time) []CertFinding {
+	var findings []CertFinding
+
+	for _, key := range keys {
+		info, err := InspectKey(key)
+		if err != nil {
+			findings = append(findings, CertFinding{NodeId: key.NodeId, Kind: CertUnreadable, Message: err.Error()})
+			continue
+		}
+
+		switch {
+		case now.After(info.NotAfter):
+			findings = append(findings, CertFinding{NodeId: key.NodeId, Kind: CertExpired, Message: "expired " + info.NotAfter.UTC().Format(time.RFC3339)})
+		case now.Before(info.NotBefore):
+			findings = append(findings, CertFinding{NodeId: key.NodeId, Kind: CertNotYetValid, Message: "valid from " + info.NotBefore.UTC().Format(time.RFC3339)})
+		case now.Add(threshold).After(info.NotAfter):
+			findings = append(findings, CertFinding{NodeId: key.NodeId, Kind: CertExpiring, Message: "expires " + info.NotAfter.UTC().Format(time.RFC3339)})
+		}
+
+		matches, err := KeyMatchesCert(key)
+		if err != nil {
+			findings = append(findings, CertFinding{NodeId: key.NodeId, Kind: CertUnreadable, Message: err.Error()})
+		} else if !matches {
+			findings = append(findings, CertFinding{NodeId: key.NodeId, Kind: CertKeyMismatch, Message: "private key does not match cert"})
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,126 @@
+package marmotcoreclient
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testCA = struct {
+	key  *rsa.PrivateKey
+	cert *x509.Certificate
+}{}
+
+func testCertAuthority(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	if testCA.cert != nil {
+		return testCA.key, testCA.cert
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Chia CA", Organization: []string{"Chia"}, OrganizationalUnit: []string{"Organic Farming Division"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCA.key = key
+	testCA.cert, _ = x509.ParseCertificate(der)
+
+	return testCA.key, testCA.cert
+}
+
+// newTestKeyPair issues a node cert from the test CA in the base64 PEM form
+// the MarmotCore API uses, valid between notBefore and notAfter.
+func newTestKeyPair(t *testing.T, nodeId string, notBefore time.Time, notAfter time.Time) Key {
+	caKey, caCert := testCertAuthority(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "Chia", Organization: []string{"Chia"}, OrganizationalUnit: []string{"Organic Farming Division"}},
+		DNSNames:     []string{"chia.net"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	return *newKey("testUserId", nodeId, base64.StdEncoding.EncodeToString(keyPem), base64.StdEncoding.EncodeToString(certPem))
+}
+
+func TestInspectKey(t *testing.T) {
+	notAfter := time.Now().Add(time.Hour).Truncate(time.Second)
+	key := newTestKeyPair(t, "chia-node", time.Now().Add(-time.Hour), notAfter)
+
+	info, err := InspectKey(key)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "chia-node", info.NodeId)
+	assert.Equal(t, "CN=Chia,OU=Organic Farming Division,O=Chia", info.Subject)
+	assert.Equal(t, "CN=Chia CA,OU=Organic Farming Division,O=Chia", info.Issuer)
+	assert.Equal(t, []string{"chia.net"}, info.DNSNames)
+	assert.True(t, notAfter.Equal(info.NotAfter))
+	assert.Equal(t, "RSA", info.PublicKeyAlgorithm)
+	assert.Equal(t, 2048, info.KeySize)
+	assert.Len(t, info.SHA256Fingerprint, 32*3-1)
+	assert.Len(t, info.SHA1Fingerprint, 20*3-1)
+}
+
+func TestCheckCerts(t *testing.T) {
+	now := time.Now()
+
+	healthy := newTestKeyPair(t, "healthy", now.Add(-time.Hour), now.Add(90*24*time.Hour))
+	expiring := newTestKeyPair(t, "expiring", now.Add(-time.Hour), now.Add(24*time.Hour))
+	expired := newTestKeyPair(t, "expired", now.Add(-2*time.Hour), now.Add(-time.Hour))
+	mismatched := newTestKeyPair(t, "mismatched", now.Add(-time.Hour), now.Add(90*24*time.Hour))
+	mismatched.Key = healthy.Key
+	unreadable := *newKey("testUserId", "unreadable", "", "bm90IGEgY2VydA==")
+
+	findings := CheckCerts([]Key{healthy, expiring, expired, mismatched, unreadable}, 30*24*time.Hour, now)
+
+	kinds := map[string]CertFindingKind{}
+	for _, finding := range findings {
+		kinds[finding.NodeId] = finding.Kind
+	}
+
+	assert.Len(t, findings, 4)
+	assert.Equal(t, map[string]CertFindingKind{
+		"expiring":   CertExpiring,
+		"expired":    CertExpired,
+		"mismatched": CertKeyMismatch,
+		"unreadable": CertUnreadable,
+	}, kinds)
+}
@@ -0,0 +1,146 @@
+package chiatest
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	marmotcoreclient "github.com/freddiecoleman/marmotcore-client"
+)
+
+// API is a fake MarmotCore API serving /nodes and /keys, and the single
+// node and key endpoints, for the fake nodes added to it. Client returns a
+// MarmotcoreClient for it, so tests don't have to replace the package's
+// HTTP client.
+type API struct {
+	server *httptest.Server
+
+	mu    sync.Mutex
+	nodes []marmotcoreclient.Node
+	keys  []marmotcoreclient.Key
+}
+
+// NewAPI starts a fake API listing nodes. Close it when done.
+func NewAPI(nodes ...*Node) *API {
+	a := &API{}
+	a.Add(nodes...)
+	a.server = httptest.NewServer(http.HandlerFunc(a.serve))
+	return a
+}
+
+func (a *API) Close() {
+	a.server.Close()
+}
+
+// Add lists more nodes, described by Node and Key as they are now.
+func (a *API) Add(nodes ...*Node) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, node := range nodes {
+		a.nodes = append(a.nodes, node.Node())
+		a.keys = append(a.keys, node.Key())
+	}
+}
+
+// AddRecords lists nodes with no fake node behind them, such as ones still
+// being provisioned or long deleted. They have no keys.
+func (a *API) AddRecords(nodes ...marmotcoreclient.Node) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.nodes = append(a.nodes, nodes...)
+}
+
+// Update changes how the API describes a node, e.g. its state while it is
+// still being provisioned.
+func (a *API) Update(nodeId string, update func(*marmotcoreclient.Node)) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i := range a.nodes {
+		if a.nodes[i].NodeId == nodeId {
+			update(&a.nodes[i])
+			return true
+		}
+	}
+	return false
+}
+
+// Delete marks a node deleted and drops its key, as deleting it through
+// the API would.
+func (a *API) Delete(nodeId string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	found := false
+	for i, node := range a.nodes {
+		if node.NodeId == nodeId && !node.Deleted {
+			a.nodes[i].Deleted = true
+			a.nodes[i].DeletedTime = int(time.Now().UnixNano() / int64(time.Millisecond))
+			a.nodes[i].State = "T"
+			found = true
+		}
+	}
+
+	keys := a.keys[:0]
+	for _, key := range a.keys {
+		if key.NodeId != nodeId {
+			keys = append(keys, key)
+		}
+	}
+	a.keys = keys
+
+	return found
+}
+
+func (a *API) Client() marmotcoreclient.MarmotcoreClient {
+	host, port, _ := net.SplitHostPort(a.server.Listener.Addr().String())
+	return marmotcoreclient.MarmotcoreClient{
+		Protocol:   "http",
+		Host:       host,
+		Port:       port,
+		ApiVersion: "v1",
+		HTTPClient: a.server.Client(),
+	}
+}
+
+func (a *API) serve(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+
+	path := strings.TrimPrefix(r.URL.Path, "/v1")
+	switch {
+	case r.Method != http.MethodGet:
+	case path == "/nodes":
+		encoder.Encode(marmotcoreclient.NodesResponse{Nodes: a.nodes})
+		return
+	case path == "/keys":
+		encoder.Encode(marmotcoreclient.KeysResponse{Keys: a.keys})
+		return
+	case strings.HasPrefix(path, "/nodes/"):
+		for _, node := range a.nodes {
+			if node.NodeId == strings.TrimPrefix(path, "/nodes/") {
+				encoder.Encode(marmotcoreclient.NodeResponse{Node: node})
+				return
+			}
+		}
+	case strings.HasPrefix(path, "/keys/"):
+		for _, key := range a.keys {
+			if key.NodeId == strings.TrimPrefix(path, "/keys/") {
+				encoder.Encode(marmotcoreclient.KeyResponse{Key: key})
+				return
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+	w.Write([]byte("{}"))
+}
@@ -0,0 +1,46 @@
+package chiatest
+
+import (
+	"context"
+	"testing"
+
+	marmotcoreclient "github.com/freddiecoleman/marmotcore-client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIServesNodesAndKeys(t *testing.T) {
+	node, err := NewNode("chia-node", "testnet")
+	assert.NoError(t, err)
+	defer node.Close()
+
+	api := NewAPI(node)
+	defer api.Close()
+	mc := api.Client()
+
+	nodes, err := mc.GetNodes()
+	assert.NoError(t, err)
+	assert.Len(t, nodes.Nodes, 1)
+	assert.Equal(t, "chia-node", nodes.Nodes[0].NodeId)
+
+	key, err := mc.GetKey("chia-node")
+	assert.NoError(t, err)
+	assert.Equal(t, node.Key().Key.Reveal(), key.Key.Key.Reveal())
+
+	resolved, err := mc.GetNode("chia-node")
+	assert.NoError(t, err)
+	rpc, err := marmotcoreclient.NewRPCClient(resolved.Node, key.Key, node.RPCOptions())
+	assert.NoError(t, err)
+	_, err = rpc.GetBlockchainState(context.Background())
+	assert.NoError(t, err)
+
+	assert.True(t, api.Delete("chia-node"))
+	assert.False(t, api.Delete("chia-node"))
+	nodes, _ = mc.GetNodes()
+	assert.True(t, nodes.Nodes[0].Deleted)
+	keys, _ := mc.GetKeys()
+	assert.Empty(t, keys.Keys)
+
+	missing, err := mc.GetNode("missing")
+	assert.NoError(t, err)
+	assert.Empty(t, missing.Node.NodeId)
+}
@@ -0,0 +1,126 @@
+// Package chiatest provides a fake Chia full node RPC server, and a fake
+// MarmotCore API listing such nodes, for testing code that talks to nodes
+// provisioned through MarmotCore.
+package chiatest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	marmotcoreclient "github.com/freddiecoleman/marmotcore-client"
+)
+
+// CA is a throwaway private CA shaped like the one every Chia installation
+// creates for itself.
+type CA struct {
+	Cert *x509.Certificate
+	key  *rsa.PrivateKey
+}
+
+var chiaName = pkix.Name{Organization: []string{"Chia"}, OrganizationalUnit: []string{"Organic Farming Division"}}
+
+func serialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func NewCA() (*CA, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	subject := chiaName
+	subject.CommonName = "Chia CA"
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               subject,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CA{Cert: cert, key: key}, nil
+}
+
+func (ca *CA) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	return pool
+}
+
+// Issue returns a PEM cert and RSA key for a node, valid for server and
+// client auth with the chia.net SAN, like private_full_node.crt.
+func (ca *CA) Issue() (certPem []byte, keyPem []byte, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	subject := chiaName
+	subject.CommonName = "Chia"
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      subject,
+		DNSNames:     []string{"chia.net"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     ca.Cert.NotAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPem = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	return certPem, keyPem, nil
+}
+
+// NewKey issues a cert and returns it in the base64 PEM form the
+// MarmotCore /keys API uses.
+func (ca *CA) NewKey(userId string, nodeId string) (marmotcoreclient.Key, error) {
+	certPem, keyPem, err := ca.Issue()
+	if err != nil {
+		return marmotcoreclient.Key{}, err
+	}
+
+	return marmotcoreclient.Key{
+		UserId: userId,
+		NodeId: nodeId,
+		Key:    marmotcoreclient.NewSecret(base64.StdEncoding.EncodeToString(keyPem)),
+		Cert:   base64.StdEncoding.EncodeToString(certPem),
+	}, nil
+}
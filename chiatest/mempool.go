@@ -0,0 +1,107 @@
+package chiatest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	marmotcoreclient "github.com/freddiecoleman/marmotcore-client"
+)
+
+// MempoolEntry is a spend bundle the fake node accepted with push_tx or
+// Add.
+type MempoolEntry struct {
+	TxId   marmotcoreclient.Bytes32
+	Bundle marmotcoreclient.SpendBundle
+	Fee    uint64
+	Cost   uint64
+	Added  time.Time
+}
+
+func (e MempoolEntry) item() marmotcoreclient.MempoolItem {
+	item := marmotcoreclient.MempoolItem{
+		SpendBundle:     e.Bundle,
+		SpendBundleName: e.TxId,
+		Fee:             e.Fee,
+		Cost:            e.Cost,
+		Additions:       []marmotcoreclient.Coin{},
+	}
+	for _, spend := range e.Bundle.CoinSpends {
+		item.Removals = append(item.Removals, spend.Coin)
+	}
+	return item
+}
+
+// Mempool holds pushed transactions until Node.Farm includes them or Drop
+// evicts them.
+type Mempool struct {
+	mu      sync.Mutex
+	entries []MempoolEntry
+}
+
+func NewMempool() *Mempool {
+	return &Mempool{}
+}
+
+func (m *Mempool) Entries() []MempoolEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]MempoolEntry(nil), m.entries...)
+}
+
+// Drop evicts a transaction, as a full mempool or a restart would.
+func (m *Mempool) Drop(txId marmotcoreclient.Bytes32) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, entry := range m.entries {
+		if entry.TxId == txId {
+			m.entries = append(m.entries[:i], m.entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Add puts a transaction in the mempool with a scripted fee and cost.
+// push_tx adds with no fee and a cost of the bundle's size.
+func (m *Mempool) Add(bundle marmotcoreclient.SpendBundle, fee uint64, cost uint64) (marmotcoreclient.Bytes32, error) {
+	txId, err := bundle.Name()
+	if err != nil {
+		return txId, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, entry := range m.entries {
+		if entry.TxId == txId {
+			return txId, fmt.Errorf("Failed to include transaction %s, error ALREADY_INCLUDING_TRANSACTION", txId)
+		}
+	}
+
+	m.entries = append(m.entries, MempoolEntry{TxId: txId, Bundle: bundle, Fee: fee, Cost: cost, Added: time.Now()})
+	return txId, nil
+}
+
+func (m *Mempool) entry(txId marmotcoreclient.Bytes32) (MempoolEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, entry := range m.entries {
+		if entry.TxId == txId {
+			return entry, true
+		}
+	}
+	return MempoolEntry{}, false
+}
+
+func (m *Mempool) take() []MempoolEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := m.entries
+	m.entries = nil
+	return entries
+}
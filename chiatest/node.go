@@ -0,0 +1,674 @@
+package chiatest
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	marmotcoreclient "github.com/freddiecoleman/marmotcore-client"
+)
+
+// Handler answers one RPC endpoint. The returned fields are sent back with
+// success set to true, a returned error as success false.
+type Handler func(request json.RawMessage) (map[string]interface{}, error)
+
+// Chain is the block data a fake node serves. It can be scripted with
+// Append or filled with Generate.
+type Chain struct {
+	mu       sync.Mutex
+	blocks   []marmotcoreclient.BlockRecord
+	coins    []marmotcoreclient.CoinRecord
+	syncMode bool
+	syncTip  uint32
+}
+
+func NewChain() *Chain {
+	return &Chain{}
+}
+
+func randomHash() marmotcoreclient.Bytes32 {
+	var b marmotcoreclient.Bytes32
+	rand.Read(b[:])
+	return b
+}
+
+// Generate appends n blocks with random hashes on top of the current peak.
+func (c *Chain) Generate(n int) []marmotcoreclient.BlockRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var generated []marmotcoreclient.BlockRecord
+	for i := 0; i < n; i++ {
+		block := marmotcoreclient.BlockRecord{
+			HeaderHash:       randomHash(),
+			PrevHash:         randomHash(),
+			Timestamp:        uint64(time.Now().Unix()),
+			FarmerPuzzleHash: marmotcoreclient.PuzzleHash(randomHash()),
+			PoolPuzzleHash:   marmotcoreclient.PuzzleHash(randomHash()),
+		}
+		if len(c.blocks) > 0 {
+			peak := c.blocks[len(c.blocks)-1]
+			block.Height = peak.Height + 1
+			block.PrevHash = peak.HeaderHash
+		}
+		c.blocks = append(c.blocks, block)
+		generated = append(generated, block)
+	}
+
+	return generated
+}
+
+// Append adds scripted blocks on top of the chain.
+func (c *Chain) Append(blocks ...marmotcoreclient.BlockRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.blocks = append(c.blocks, blocks...)
+}
+
+func (c *Chain) Blocks() []marmotcoreclient.BlockRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]marmotcoreclient.BlockRecord(nil), c.blocks...)
+}
+
+// Fork replaces the blocks above height with n new ones, as a reorg would.
+func (c *Chain) Fork(height uint32, n int) []marmotcoreclient.BlockRecord {
+	c.mu.Lock()
+	for i, block := range c.blocks {
+		if block.Height > height {
+			c.blocks = c.blocks[:i]
+			break
+		}
+	}
+	c.mu.Unlock()
+
+	return c.Generate(n)
+}
+
+// AddCoins adds coin records for the coin store endpoints to serve.
+func (c *Chain) AddCoins(records ...marmotcoreclient.CoinRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.coins = append(c.coins, records...)
+}
+
+// SpendCoin marks the coin with the given ID spent at height.
+func (c *Chain) SpendCoin(coinId marmotcoreclient.Bytes32, height uint32) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.coins {
+		if c.coins[i].Coin.ID() == coinId {
+			c.coins[i].Spent = true
+			c.coins[i].SpentBlockIndex = height
+			return true
+		}
+	}
+	return false
+}
+
+// spend marks a coin spent at height, adding its record if the chain
+// doesn't know it yet.
+func (c *Chain) spend(coin marmotcoreclient.Coin, height uint32) {
+	if c.SpendCoin(coin.ID(), height) {
+		return
+	}
+	c.AddCoins(marmotcoreclient.CoinRecord{Coin: coin, Spent: true, SpentBlockIndex: height})
+}
+
+func (c *Chain) spent(coinId marmotcoreclient.Bytes32) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, record := range c.coins {
+		if record.Coin.ID() == coinId {
+			return record.Spent
+		}
+	}
+	return false
+}
+
+func (c *Chain) coinsByNames(names []marmotcoreclient.Bytes32, start uint32, end uint32, includeSpent bool) []marmotcoreclient.CoinRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wanted := map[marmotcoreclient.Bytes32]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	records := []marmotcoreclient.CoinRecord{}
+	for _, record := range c.coins {
+		if !wanted[record.Coin.ID()] || (record.Spent && !includeSpent) {
+			continue
+		}
+		if record.ConfirmedBlockIndex < start || record.ConfirmedBlockIndex >= end {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func (c *Chain) additionsAndRemovals(height uint32) ([]marmotcoreclient.CoinRecord, []marmotcoreclient.CoinRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	additions := []marmotcoreclient.CoinRecord{}
+	removals := []marmotcoreclient.CoinRecord{}
+	for _, record := range c.coins {
+		if record.ConfirmedBlockIndex == height {
+			additions = append(additions, record)
+		}
+		if record.Spent && record.SpentBlockIndex == height {
+			removals = append(removals, record)
+		}
+	}
+	return additions, removals
+}
+
+func (c *Chain) coinsByPuzzleHashes(puzzleHashes []marmotcoreclient.PuzzleHash, start uint32, end uint32, includeSpent bool) []marmotcoreclient.CoinRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wanted := map[marmotcoreclient.PuzzleHash]bool{}
+	for _, puzzleHash := range puzzleHashes {
+		wanted[puzzleHash] = true
+	}
+
+	records := []marmotcoreclient.CoinRecord{}
+	for _, record := range c.coins {
+		if !wanted[record.Coin.PuzzleHash] || (record.Spent && !includeSpent) {
+			continue
+		}
+		if record.ConfirmedBlockIndex < start || record.ConfirmedBlockIndex >= end {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// SetSyncing makes the node report it is syncing towards tip, with the
+// current peak as its progress. A zero tip marks it synced again.
+func (c *Chain) SetSyncing(tip uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.syncMode = tip != 0
+	c.syncTip = tip
+}
+
+func (c *Chain) state() marmotcoreclient.BlockchainState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := marmotcoreclient.BlockchainState{
+		Difficulty: 1024,
+		Sync: marmotcoreclient.SyncState{
+			SyncMode: c.syncMode,
+			Synced:   !c.syncMode && len(c.blocks) > 0,
+		},
+	}
+
+	if len(c.blocks) > 0 {
+		peak := c.blocks[len(c.blocks)-1]
+		state.Peak = &peak
+	}
+	if c.syncMode {
+		state.Sync.SyncTipHeight = c.syncTip
+		state.Sync.SyncProgressHeight = state.PeakHeight()
+	}
+
+	return state
+}
+
+func (c *Chain) byHeight(height uint32) (marmotcoreclient.BlockRecord, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, block := range c.blocks {
+		if block.Height == height {
+			return block, true
+		}
+	}
+	return marmotcoreclient.BlockRecord{}, false
+}
+
+func (c *Chain) byHash(headerHash marmotcoreclient.Bytes32) (marmotcoreclient.BlockRecord, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, block := range c.blocks {
+		if block.HeaderHash == headerHash {
+			return block, true
+		}
+	}
+	return marmotcoreclient.BlockRecord{}, false
+}
+
+// Node is a fake full node serving the common RPC endpoints over mutual
+// TLS on 127.0.0.1. Clients must present a cert from the node's CA, which
+// is what Key returns, so it slots in wherever GetKey would be used.
+type Node struct {
+	NodeId  string
+	UserId  string
+	Network string
+	CA      *CA
+	Chain   *Chain
+	Mempool *Mempool
+
+	// PeerId is the Chia node ID the node reports and peers see.
+	PeerId marmotcoreclient.Bytes32
+
+	server *httptest.Server
+	key    marmotcoreclient.Key
+
+	mu          sync.Mutex
+	handlers    map[string]Handler
+	connections []marmotcoreclient.Connection
+}
+
+// NewNode starts a fake node with an empty chain. Close it when done.
+func NewNode(nodeId string, network string) (*Node, error) {
+	ca, err := NewCA()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ca.NewKey("testUserId", nodeId)
+	if err != nil {
+		return nil, err
+	}
+
+	certPem, _ := base64.StdEncoding.DecodeString(key.Cert)
+	var certificate tls.Certificate
+	err = key.Key.Use(func(keyPem []byte) error {
+		certificate, err = tls.X509KeyPair(certPem, keyPem)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Node{
+		NodeId:   nodeId,
+		UserId:   key.UserId,
+		Network:  network,
+		CA:       ca,
+		Chain:    NewChain(),
+		Mempool:  NewMempool(),
+		PeerId:   randomHash(),
+		key:      key,
+		handlers: map[string]Handler{},
+	}
+	n.registerDefaults()
+
+	n.server = httptest.NewUnstartedServer(http.HandlerFunc(n.serve))
+	n.server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    ca.CertPool(),
+	}
+	n.server.StartTLS()
+
+	return n, nil
+}
+
+func (n *Node) Close() {
+	n.server.Close()
+}
+
+func (n *Node) Port() string {
+	_, port, _ := net.SplitHostPort(n.server.Listener.Addr().String())
+	return port
+}
+
+// Node describes the fake node the way GetNode would.
+func (n *Node) Node() marmotcoreclient.Node {
+	return marmotcoreclient.Node{
+		UserId:       n.UserId,
+		CreatedTime:  time.Now().UnixNano() / int64(time.Millisecond),
+		NodeId:       n.NodeId,
+		PublicIp:     "127.0.0.1",
+		Region:       "local",
+		InstanceType: "node.small",
+		ChiaVersion:  "1.3.*",
+		Network:      n.Network,
+		State:        "R",
+	}
+}
+
+// Key returns client credentials for the node, as GetKey would.
+func (n *Node) Key() marmotcoreclient.Key {
+	return n.key
+}
+
+// RPCOptions connect to the fake node. Its CA is throwaway, so server
+// verification is skipped.
+func (n *Node) RPCOptions() marmotcoreclient.RPCOptions {
+	return marmotcoreclient.RPCOptions{Port: n.Port(), InsecureSkipVerify: true}
+}
+
+func (n *Node) RPCClient() (*marmotcoreclient.RPCClient, error) {
+	return marmotcoreclient.NewRPCClient(n.Node(), n.Key(), n.RPCOptions())
+}
+
+// Farm adds a block that includes every transaction in the mempool,
+// spending their coins at the new height.
+func (n *Node) Farm() marmotcoreclient.BlockRecord {
+	block := n.Chain.Generate(1)[0]
+	for _, entry := range n.Mempool.take() {
+		for _, spend := range entry.Bundle.CoinSpends {
+			n.Chain.spend(spend.Coin, block.Height)
+		}
+	}
+	return block
+}
+
+// Connect adds a peer connection for get_connections to report.
+func (n *Node) Connect(connection marmotcoreclient.Connection) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.connections = append(n.connections, connection)
+}
+
+func (n *Node) Connections() []marmotcoreclient.Connection {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return append([]marmotcoreclient.Connection{}, n.connections...)
+}
+
+// Handle replaces the handler for an endpoint, for scripting replies.
+func (n *Node) Handle(endpoint string, handler Handler) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.handlers[endpoint] = handler
+}
+
+func (n *Node) serve(w http.ResponseWriter, r *http.Request) {
+	endpoint := strings.TrimPrefix(r.URL.Path, "/")
+
+	n.mu.Lock()
+	handler, ok := n.handlers[endpoint]
+	n.mu.Unlock()
+
+	body, _ := ioutil.ReadAll(r.Body)
+	if len(body) == 0 {
+		body = []byte("{}")
+	}
+
+	response := map[string]interface{}{}
+	var err error
+	if !ok {
+		err = fmt.Errorf("No such endpoint: %s", endpoint)
+	} else {
+		response, err = handler(body)
+	}
+
+	if err != nil {
+		response = map[string]interface{}{"success": false, "error": err.Error()}
+	} else {
+		if response == nil {
+			response = map[string]interface{}{}
+		}
+		response["success"] = true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (n *Node) registerDefaults() {
+	n.handlers["get_blockchain_state"] = func(request json.RawMessage) (map[string]interface{}, error) {
+		state := n.Chain.state()
+		state.NodeId = n.PeerId.String()
+		return map[string]interface{}{"blockchain_state": state}, nil
+	}
+
+	n.handlers["get_block_record_by_height"] = func(request json.RawMessage) (map[string]interface{}, error) {
+		var params struct {
+			Height *uint32 `json:"height"`
+		}
+		if err := json.Unmarshal(request, &params); err != nil || params.Height == nil {
+			return nil, fmt.Errorf("No height in request")
+		}
+
+		block, ok := n.Chain.byHeight(*params.Height)
+		if !ok {
+			return nil, fmt.Errorf("Block height %d not found in chain", *params.Height)
+		}
+		return map[string]interface{}{"block_record": block}, nil
+	}
+
+	n.handlers["get_block_record"] = func(request json.RawMessage) (map[string]interface{}, error) {
+		var params struct {
+			HeaderHash marmotcoreclient.Bytes32 `json:"header_hash"`
+		}
+		json.Unmarshal(request, &params)
+
+		block, ok := n.Chain.byHash(params.HeaderHash)
+		if !ok {
+			return nil, fmt.Errorf("Block %s not found", params.HeaderHash)
+		}
+		return map[string]interface{}{"block_record": block}, nil
+	}
+
+	n.handlers["get_block_records"] = func(request json.RawMessage) (map[string]interface{}, error) {
+		var params struct {
+			Start uint32 `json:"start"`
+			End   uint32 `json:"end"`
+		}
+		json.Unmarshal(request, &params)
+
+		records := []marmotcoreclient.BlockRecord{}
+		for _, block := range n.Chain.Blocks() {
+			if block.Height >= params.Start && block.Height < params.End {
+				records = append(records, block)
+			}
+		}
+		return map[string]interface{}{"block_records": records}, nil
+	}
+
+	n.handlers["get_coin_records_by_puzzle_hashes"] = func(request json.RawMessage) (map[string]interface{}, error) {
+		params := struct {
+			PuzzleHashes      []marmotcoreclient.PuzzleHash `json:"puzzle_hashes"`
+			StartHeight       uint32                        `json:"start_height"`
+			EndHeight         uint32                        `json:"end_height"`
+			IncludeSpentCoins bool                          `json:"include_spent_coins"`
+		}{EndHeight: math.MaxUint32}
+		if err := json.Unmarshal(request, &params); err != nil {
+			return nil, err
+		}
+
+		records := n.Chain.coinsByPuzzleHashes(params.PuzzleHashes, params.StartHeight, params.EndHeight, params.IncludeSpentCoins)
+		return map[string]interface{}{"coin_records": records}, nil
+	}
+
+	n.handlers["get_additions_and_removals"] = func(request json.RawMessage) (map[string]interface{}, error) {
+		var params struct {
+			HeaderHash marmotcoreclient.Bytes32 `json:"header_hash"`
+		}
+		json.Unmarshal(request, &params)
+
+		block, ok := n.Chain.byHash(params.HeaderHash)
+		if !ok {
+			return nil, fmt.Errorf("Block %s not found", params.HeaderHash)
+		}
+
+		additions, removals := n.Chain.additionsAndRemovals(block.Height)
+		return map[string]interface{}{"additions": additions, "removals": removals}, nil
+	}
+
+	n.handlers["get_coin_records_by_names"] = func(request json.RawMessage) (map[string]interface{}, error) {
+		params := struct {
+			Names             []marmotcoreclient.Bytes32 `json:"names"`
+			StartHeight       uint32                     `json:"start_height"`
+			EndHeight         uint32                     `json:"end_height"`
+			IncludeSpentCoins bool                       `json:"include_spent_coins"`
+		}{EndHeight: math.MaxUint32}
+		if err := json.Unmarshal(request, &params); err != nil {
+			return nil, err
+		}
+
+		records := n.Chain.coinsByNames(params.Names, params.StartHeight, params.EndHeight, params.IncludeSpentCoins)
+		return map[string]interface{}{"coin_records": records}, nil
+	}
+
+	n.handlers["push_tx"] = func(request json.RawMessage) (map[string]interface{}, error) {
+		var params struct {
+			SpendBundle marmotcoreclient.SpendBundle `json:"spend_bundle"`
+		}
+		if err := json.Unmarshal(request, &params); err != nil {
+			return nil, err
+		}
+
+		for _, removal := range params.SpendBundle.Removals() {
+			if n.Chain.spent(removal) {
+				txId, _ := params.SpendBundle.Name()
+				return nil, fmt.Errorf("Failed to include transaction %s, error DOUBLE_SPEND", txId)
+			}
+		}
+		serialised, err := params.SpendBundle.Serialise()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := n.Mempool.Add(params.SpendBundle, 0, uint64(len(serialised))); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"status": "SUCCESS"}, nil
+	}
+
+	n.handlers["get_all_mempool_tx_ids"] = func(request json.RawMessage) (map[string]interface{}, error) {
+		txIds := []marmotcoreclient.Bytes32{}
+		for _, entry := range n.Mempool.Entries() {
+			txIds = append(txIds, entry.TxId)
+		}
+		return map[string]interface{}{"tx_ids": txIds}, nil
+	}
+
+	n.handlers["get_all_mempool_items"] = func(request json.RawMessage) (map[string]interface{}, error) {
+		items := map[string]marmotcoreclient.MempoolItem{}
+		for _, entry := range n.Mempool.Entries() {
+			items[entry.TxId.String()] = entry.item()
+		}
+		return map[string]interface{}{"mempool_items": items}, nil
+	}
+
+	n.handlers["get_mempool_item_by_tx_id"] = func(request json.RawMessage) (map[string]interface{}, error) {
+		var params struct {
+			TxId marmotcoreclient.Bytes32 `json:"tx_id"`
+		}
+		json.Unmarshal(request, &params)
+
+		entry, ok := n.Mempool.entry(params.TxId)
+		if !ok {
+			return nil, fmt.Errorf("Tx id 0x%x not in the mempool", params.TxId[:])
+		}
+		return map[string]interface{}{"mempool_item": entry.item()}, nil
+	}
+
+	// Fees are estimated at the lowest fee rate in the mempool, whatever
+	// the target time.
+	n.handlers["get_fee_estimate"] = func(request json.RawMessage) (map[string]interface{}, error) {
+		var params struct {
+			TargetTimes []uint64 `json:"target_times"`
+			Cost        uint64   `json:"cost"`
+		}
+		json.Unmarshal(request, &params)
+
+		rate, size, first := 0.0, uint64(0), true
+		for _, entry := range n.Mempool.Entries() {
+			size += entry.Cost
+			if entry.Cost == 0 {
+				continue
+			}
+			if entryRate := float64(entry.Fee) / float64(entry.Cost); first || entryRate < rate {
+				rate, first = entryRate, false
+			}
+		}
+
+		estimates := []uint64{}
+		for range params.TargetTimes {
+			estimates = append(estimates, uint64(math.Ceil(rate*float64(params.Cost))))
+		}
+
+		state := n.Chain.state()
+		return map[string]interface{}{
+			"estimates":        estimates,
+			"target_times":     params.TargetTimes,
+			"current_fee_rate": rate,
+			"mempool_size":     size,
+			"mempool_max_size": 550000000000,
+			"full_node_synced": state.Sync.Synced,
+			"peak_height":      state.PeakHeight(),
+		}, nil
+	}
+
+	n.handlers["get_network_info"] = func(request json.RawMessage) (map[string]interface{}, error) {
+		prefix, err := marmotcoreclient.AddressPrefix(n.Network)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"network_name": n.Network, "network_prefix": prefix}, nil
+	}
+
+	n.handlers["get_connections"] = func(request json.RawMessage) (map[string]interface{}, error) {
+		return map[string]interface{}{"connections": n.Connections()}, nil
+	}
+
+	// Opened connections get a random peer ID, as the fake node can't
+	// reach the peer to learn its real one.
+	n.handlers["open_connection"] = func(request json.RawMessage) (map[string]interface{}, error) {
+		var params struct {
+			Host string `json:"host"`
+			Port int    `json:"port"`
+		}
+		json.Unmarshal(request, &params)
+		if params.Host == "" || params.Port == 0 {
+			return nil, fmt.Errorf("Invalid host or port")
+		}
+
+		n.Connect(marmotcoreclient.Connection{
+			NodeId:         randomHash(),
+			PeerHost:       params.Host,
+			PeerPort:       params.Port,
+			PeerServerPort: params.Port,
+			Type:           marmotcoreclient.NodeTypeFullNode,
+		})
+		return map[string]interface{}{}, nil
+	}
+
+	n.handlers["close_connection"] = func(request json.RawMessage) (map[string]interface{}, error) {
+		var params struct {
+			NodeId marmotcoreclient.Bytes32 `json:"node_id"`
+		}
+		json.Unmarshal(request, &params)
+
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		for i, connection := range n.connections {
+			if connection.NodeId == params.NodeId {
+				n.connections = append(n.connections[:i], n.connections[i+1:]...)
+				return map[string]interface{}{}, nil
+			}
+		}
+		return nil, fmt.Errorf("connection with node_id %s does not exist", params.NodeId)
+	}
+}
@@ -0,0 +1,225 @@
+package chiatest
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	marmotcoreclient "github.com/freddiecoleman/marmotcore-client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeServesGeneratedChain(t *testing.T) {
+	node, err := NewNode("chia-node", "testnet")
+	assert.NoError(t, err)
+	defer node.Close()
+
+	blocks := node.Chain.Generate(5)
+	rpc, err := node.RPCClient()
+	assert.NoError(t, err)
+
+	state, err := rpc.GetBlockchainState(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, state.Sync.Synced)
+	assert.Equal(t, uint32(4), state.PeakHeight())
+	assert.Equal(t, blocks[4].HeaderHash, state.Peak.HeaderHash)
+	assert.Equal(t, blocks[3].HeaderHash, state.Peak.PrevHash)
+
+	node.Chain.SetSyncing(8)
+	state, _ = rpc.GetBlockchainState(context.Background())
+	assert.Equal(t, 50.0, state.SyncProgress())
+
+	var response struct {
+		BlockRecord marmotcoreclient.BlockRecord `json:"block_record"`
+	}
+	err = rpc.Call(context.Background(), "get_block_record_by_height", map[string]uint32{"height": 2}, &response)
+	assert.NoError(t, err)
+	assert.Equal(t, blocks[2], response.BlockRecord)
+
+	err = rpc.Call(context.Background(), "get_block_record_by_height", map[string]uint32{"height": 9}, &response)
+	var rpcErr *marmotcoreclient.RPCError
+	assert.True(t, errors.As(err, &rpcErr))
+}
+
+func TestNodeScriptedHandler(t *testing.T) {
+	node, err := NewNode("chia-node", "mainnet")
+	assert.NoError(t, err)
+	defer node.Close()
+
+	node.Handle("get_network_info", func(request json.RawMessage) (map[string]interface{}, error) {
+		return map[string]interface{}{"network_name": "scripted"}, nil
+	})
+
+	rpc, _ := node.RPCClient()
+	var info struct {
+		NetworkName string `json:"network_name"`
+	}
+	assert.NoError(t, rpc.Call(context.Background(), "get_network_info", nil, &info))
+	assert.Equal(t, "scripted", info.NetworkName)
+}
+
+func TestNodeRequiresClientCertFromItsCA(t *testing.T) {
+	node, err := NewNode("chia-node", "testnet")
+	assert.NoError(t, err)
+	defer node.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	_, err = client.Post("https://127.0.0.1:"+node.Port()+"/get_blockchain_state", "application/json", nil)
+	assert.Error(t, err)
+
+	other, _ := NewCA()
+	key, _ := other.NewKey("testUserId", "chia-node")
+	rpc, _ := marmotcoreclient.NewRPCClient(node.Node(), key, node.RPCOptions())
+	_, err = rpc.GetBlockchainState(context.Background())
+	assert.Error(t, err)
+}
+
+func TestNodeServesCoinRecords(t *testing.T) {
+	node, err := NewNode("chia-node", "testnet")
+	assert.NoError(t, err)
+	defer node.Close()
+
+	puzzleHash := marmotcoreclient.PuzzleHash(randomHash())
+	spent := marmotcoreclient.Coin{ParentCoinInfo: randomHash(), PuzzleHash: puzzleHash, Amount: 250}
+	node.Chain.AddCoins(
+		marmotcoreclient.CoinRecord{Coin: marmotcoreclient.Coin{ParentCoinInfo: randomHash(), PuzzleHash: puzzleHash, Amount: 1000}, ConfirmedBlockIndex: 10},
+		marmotcoreclient.CoinRecord{Coin: spent, ConfirmedBlockIndex: 20},
+		marmotcoreclient.CoinRecord{Coin: marmotcoreclient.Coin{ParentCoinInfo: randomHash(), PuzzleHash: marmotcoreclient.PuzzleHash(randomHash()), Amount: 7}},
+	)
+	assert.True(t, node.Chain.SpendCoin(spent.ID(), 30))
+
+	rpc, _ := node.RPCClient()
+	records, err := rpc.GetCoinRecordsByPuzzleHashes(context.Background(), []marmotcoreclient.PuzzleHash{puzzleHash}, marmotcoreclient.CoinQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, uint64(1000), records[0].Coin.Amount)
+
+	start := uint32(15)
+	records, err = rpc.GetCoinRecordsByPuzzleHashes(context.Background(), []marmotcoreclient.PuzzleHash{puzzleHash}, marmotcoreclient.CoinQuery{IncludeSpent: true, StartHeight: &start})
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, uint32(30), records[0].SpentBlockIndex)
+}
+
+func TestNodeForkAndAdditions(t *testing.T) {
+	node, err := NewNode("chia-node", "testnet")
+	assert.NoError(t, err)
+	defer node.Close()
+
+	blocks := node.Chain.Generate(4)
+	node.Chain.AddCoins(marmotcoreclient.CoinRecord{Coin: marmotcoreclient.Coin{Amount: 1}, ConfirmedBlockIndex: 3})
+
+	rpc, _ := node.RPCClient()
+	additions, removals, err := rpc.GetAdditionsAndRemovals(context.Background(), blocks[3].HeaderHash)
+	assert.NoError(t, err)
+	assert.Len(t, additions, 1)
+	assert.Empty(t, removals)
+
+	forked := node.Chain.Fork(1, 3)
+	assert.Equal(t, uint32(2), forked[0].Height)
+	assert.Equal(t, blocks[1].HeaderHash, forked[0].PrevHash)
+
+	block, err := rpc.GetBlockRecordByHeight(context.Background(), 4)
+	assert.NoError(t, err)
+	assert.Equal(t, forked[2], block)
+
+	_, err = rpc.GetBlockRecord(context.Background(), blocks[3].HeaderHash)
+	assert.Error(t, err)
+}
+
+func TestNodeMempoolAndFarm(t *testing.T) {
+	node, err := NewNode("chia-node", "testnet")
+	assert.NoError(t, err)
+	defer node.Close()
+	node.Chain.Generate(2)
+
+	bundle := marmotcoreclient.SpendBundle{
+		CoinSpends:          []marmotcoreclient.CoinSpend{{Coin: marmotcoreclient.Coin{ParentCoinInfo: randomHash(), Amount: 5}, PuzzleReveal: "0x80", Solution: "0x80"}},
+		AggregatedSignature: "0xc0" + strings.Repeat("00", 95),
+	}
+
+	rpc, _ := node.RPCClient()
+	status, err := rpc.PushTx(context.Background(), bundle)
+	assert.NoError(t, err)
+	assert.Equal(t, "SUCCESS", status)
+
+	_, err = rpc.PushTx(context.Background(), bundle)
+	assert.Contains(t, err.Error(), "ALREADY_INCLUDING_TRANSACTION")
+	assert.Len(t, node.Mempool.Entries(), 1)
+
+	block := node.Farm()
+	assert.Empty(t, node.Mempool.Entries())
+
+	records, err := rpc.GetCoinRecordsByNames(context.Background(), bundle.Removals(), marmotcoreclient.CoinQuery{IncludeSpent: true})
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, block.Height, records[0].SpentBlockIndex)
+
+	_, err = rpc.PushTx(context.Background(), bundle)
+	assert.Contains(t, err.Error(), "DOUBLE_SPEND")
+}
+
+func TestNodeMempoolEndpoints(t *testing.T) {
+	node, err := NewNode("chia-node", "testnet")
+	assert.NoError(t, err)
+	defer node.Close()
+	node.Chain.Generate(1)
+
+	bundle := marmotcoreclient.SpendBundle{
+		CoinSpends:          []marmotcoreclient.CoinSpend{{Coin: marmotcoreclient.Coin{ParentCoinInfo: randomHash(), Amount: 5}, PuzzleReveal: "0x80", Solution: "0x80"}},
+		AggregatedSignature: "0xc0" + strings.Repeat("00", 95),
+	}
+	txId, err := node.Mempool.Add(bundle, 5000, 1000)
+	assert.NoError(t, err)
+
+	rpc, _ := node.RPCClient()
+	txIds, err := rpc.GetAllMempoolTxIds(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []marmotcoreclient.Bytes32{txId}, txIds)
+
+	item, err := rpc.GetMempoolItemByTxId(context.Background(), txId)
+	assert.NoError(t, err)
+	assert.Equal(t, 5.0, item.FeeRate())
+	assert.Equal(t, bundle.CoinSpends[0].Coin, item.Removals[0])
+
+	items, err := rpc.GetAllMempoolItems(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, item, items[txId])
+
+	estimate, err := rpc.GetFeeEstimate(context.Background(), []uint64{60, 300}, 2000)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{10000, 10000}, estimate.Estimates)
+	assert.Equal(t, 5.0, estimate.CurrentFeeRate)
+	assert.True(t, estimate.FullNodeSynced)
+
+	node.Mempool.Drop(txId)
+	_, err = rpc.GetMempoolItemByTxId(context.Background(), txId)
+	assert.Error(t, err)
+}
+
+func TestNodeConnections(t *testing.T) {
+	node, err := NewNode("chia-node", "testnet")
+	assert.NoError(t, err)
+	defer node.Close()
+	node.Chain.Generate(1)
+
+	rpc, _ := node.RPCClient()
+	state, err := rpc.GetBlockchainState(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, node.PeerId.String(), state.NodeId)
+
+	assert.NoError(t, rpc.OpenConnection(context.Background(), "10.0.0.2", "58444"))
+	connections, err := rpc.GetConnections(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, connections, 1)
+	assert.Equal(t, "10.0.0.2", connections[0].PeerHost)
+	assert.Equal(t, 58444, connections[0].PeerPort)
+
+	assert.NoError(t, rpc.CloseConnection(context.Background(), connections[0].NodeId))
+	assert.Empty(t, node.Connections())
+	assert.Error(t, rpc.CloseConnection(context.Background(), connections[0].NodeId))
+}
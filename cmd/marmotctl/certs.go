@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	marmotcoreclient "github.com/freddiecoleman/marmotcore-client"
+)
+
+func runCerts(args []string) error {
+	flags, profile := newFlagSet("certs")
+	threshold := flags.Duration("expiring-within", 30*24*time.Hour, "flag certs that expire within this duration")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Parse(args)
+
+	mc, err := newClient(*profile)
+	if err != nil {
+		return err
+	}
+
+	keys, err := mc.GetKeys()
+	if err != nil {
+		return err
+	}
+
+	var infos []marmotcoreclient.CertInfo
+	for _, key := range keys.Keys {
+		if info, err := marmotcoreclient.InspectKey(key); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	findings := marmotcoreclient.CheckCerts(keys.Keys, *threshold, time.Now())
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(struct {
+			Certs    []marmotcoreclient.CertInfo    `json:"certs"`
+			Findings []marmotcoreclient.CertFinding `json:"findings"`
+		}{infos, findings})
+	} else {
+		err = printCerts(infos, findings)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(findings) > 0 {
+		return fmt.Errorf("%d certificate problem(s) found", len(findings))
+	}
+	return nil
+}
+
+func printCerts(infos []marmotcoreclient.CertInfo, findings []marmotcoreclient.CertFinding) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "NODE\tSUBJECT\tSANS\tISSUER\tNOT AFTER\tKEY\tSHA256")
+	for _, info := range infos {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s %d\t%s\n",
+			info.NodeId,
+			info.Subject,
+			strings.Join(append(info.DNSNames, info.IPAddresses...), ","),
+			info.Issuer,
+			info.NotAfter.UTC().Format("2006-01-02"),
+			info.PublicKeyAlgorithm,
+			info.KeySize,
+			info.SHA256Fingerprint,
+		)
+	}
+
+	if len(findings) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "NODE\tPROBLEM\tDETAIL")
+		for _, finding := range findings {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", finding.NodeId, finding.Kind, finding.Message)
+		}
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	marmotcoreclient "github.com/freddiecoleman/marmotcore-client"
+)
+
+func runCosts(args []string) error {
+	flags, profile := newFlagSet("costs")
+	pricing := flags.String("pricing", "", "pricing table file (YAML or JSON)")
+	month := flags.String("month", "", "billing month, e.g. 2022-03 (default the current month to date)")
+	from := flags.String("from", "", "start of the billing window (RFC 3339 or 2006-01-02, default the start of this month)")
+	to := flags.String("to", "", "end of the billing window (default now)")
+	groupBy := flags.String("group-by", "", "aggregate by region, instance_type, network, day or month")
+	format := flags.String("format", "table", "output format: table, csv or json")
+	flags.Parse(args)
+
+	if *pricing == "" {
+		flags.Usage()
+		return errors.New("-pricing is required")
+	}
+
+	table, err := marmotcoreclient.LoadPricingTable(*pricing)
+	if err != nil {
+		return err
+	}
+
+	start, end, err := billingWindow(*month, *from, *to, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var dimension marmotcoreclient.CostDimension
+	if *groupBy != "" {
+		if dimension, err = marmotcoreclient.ParseCostDimension(*groupBy); err != nil {
+			return err
+		}
+	}
+
+	mc, err := newClient(*profile)
+	if err != nil {
+		return err
+	}
+
+	report, err := mc.Costs(table, start, end)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *format == "json" && dimension != "":
+		return printJSON(struct {
+			Currency string                         `json:"currency"`
+			Start    time.Time                      `json:"start"`
+			End      time.Time                      `json:"end"`
+			GroupBy  marmotcoreclient.CostDimension `json:"group_by"`
+			Groups   []marmotcoreclient.CostGroup   `json:"groups"`
+		}{report.Currency, report.Start, report.End, dimension, report.GroupBy(dimension)})
+	case *format == "json":
+		return printJSON(report)
+	case *format == "csv" && dimension != "":
+		return marmotcoreclient.WriteCostGroupsCSV(os.Stdout, dimension, report.GroupBy(dimension))
+	case *format == "csv":
+		return report.WriteCSV(os.Stdout)
+	case *format == "table" && dimension != "":
+		return marmotcoreclient.WriteCostGroupsTable(os.Stdout, dimension, report.GroupBy(dimension))
+	case *format == "table":
+		return report.WriteTable(os.Stdout)
+	}
+
+	return fmt.Errorf("unknown format %q", *format)
+}
+
+// billingWindow is either a calendar month (UTC), capped at now for the
+// current month, or from/to, which default to the start of this month and
+// now.
+func billingWindow(month, from, to string, now time.Time) (time.Time, time.Time, error) {
+	if month != "" && (from != "" || to != "") {
+		return time.Time{}, time.Time{}, errors.New("-month can't be combined with -from or -to")
+	}
+
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := now
+	var err error
+
+	switch {
+	case month != "":
+		if start, err = time.Parse("2006-01", month); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("-month: %w", err)
+		}
+		if monthEnd := start.AddDate(0, 1, 0); monthEnd.Before(now) {
+			end = monthEnd
+		}
+	case from != "":
+		if start, err = parseTime(from); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("-from: %w", err)
+		}
+	}
+
+	if to != "" {
+		if end, err = parseTime(to); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("-to: %w", err)
+		}
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("the billing window must end after it starts: %s to %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+
+	return start, end, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
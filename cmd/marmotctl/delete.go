@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	marmotcoreclient "github.com/freddiecoleman/marmotcore-client"
+)
+
+func runDelete(args []string) error {
+	flags, profile := newFlagSet("delete")
+	confirm := flags.String("confirm", "", "comma separated node ids confirming the delete (default prompt for each)")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: marmotctl delete [-confirm id,...] <node-id>...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("no node ids given")
+	}
+
+	mc, err := newClient(*profile)
+	if err != nil {
+		return err
+	}
+	if mc.Protection == nil {
+		mc.Protection = &marmotcoreclient.DeletionProtection{}
+	}
+
+	var nodeIds []string
+	for _, nodeId := range flags.Args() {
+		if !contains(nodeIds, nodeId) {
+			nodeIds = append(nodeIds, nodeId)
+		}
+	}
+
+	var confirmations []string
+	if *confirm != "" {
+		confirmations = strings.Split(*confirm, ",")
+	} else {
+		input := bufio.NewScanner(os.Stdin)
+		for _, nodeId := range nodeIds {
+			fmt.Fprintf(os.Stderr, "type the node id to delete %s: ", nodeId)
+			if !input.Scan() {
+				return errors.New("delete not confirmed")
+			}
+			confirmations = append(confirmations, strings.TrimSpace(input.Text()))
+		}
+	}
+
+	responses, err := mc.DeleteNodes(nodeIds, confirmations)
+	for i, response := range responses {
+		fmt.Printf("%s deleted=%t\n", nodeIds[i], response.Deleted)
+	}
+	return err
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func runProtect(args []string) error {
+	defaultPath, _ := marmotcoreclient.DefaultProtectedNodesPath()
+
+	flags, profile := newFlagSet("protect")
+	path := flags.String("file", "", "protected nodes file (default the profile's protected_file or "+defaultPath+")")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: marmotctl protect [-file path] list | add <node-id|selector> | remove <node-id|selector>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *path == "" {
+		*path = defaultPath
+		if loaded, err := marmotcoreclient.LoadProfile(*profile); err == nil && loaded.Protection != nil && loaded.Protection.ProtectedFile != "" {
+			*path = loaded.Protection.ProtectedFile
+		}
+	}
+
+	set := &marmotcoreclient.ProtectedSet{Path: *path}
+
+	switch {
+	case flags.NArg() == 0 || flags.Arg(0) == "list":
+		entries, err := set.Entries()
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			fmt.Println(entry)
+		}
+		return nil
+	case flags.NArg() == 2 && flags.Arg(0) == "add":
+		return set.Add(flags.Arg(1))
+	case flags.NArg() == 2 && flags.Arg(0) == "remove":
+		return set.Remove(flags.Arg(1))
+	}
+
+	flags.Usage()
+	return errors.New("invalid arguments")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	marmotcoreclient "github.com/freddiecoleman/marmotcore-client"
+	"github.com/freddiecoleman/marmotcore-client/exporter"
+)
+
+func runExporter(args []string) error {
+	flags, profile := newFlagSet("exporter")
+	listen := flags.String("listen", "127.0.0.1:9792", "address to serve /metrics on")
+	interval := flags.Duration("interval", 0, "time between collections (default 1m)")
+	network := flags.String("network", "", "only export nodes on this network")
+	groupBy := flags.String("group-by", strings.Join(exporter.DefaultGroupBy, ","), "node labels to count nodes by: region, state, instance_type, network, chia_version")
+	maxNodes := flags.Int("max-nodes", exporter.DefaultMaxNodes, "limit on nodes with per-node series, -1 for none")
+	chain := flags.Bool("chain", false, "collect peak height, sync status, peers and mempool size over RPC")
+	rpcOptions := addRPCFlags(flags)
+	useVault := addVaultFlag(flags)
+	flags.Parse(args)
+
+	client, err := newClient(*profile)
+	if err != nil {
+		return err
+	}
+
+	opts := rpcOptions()
+	if err := useVault(&opts); err != nil {
+		return err
+	}
+
+	metrics := marmotcoreclient.NewMetrics(nil)
+	marmotcoreclient.Instrumentation = metrics
+
+	e := &exporter.Exporter{
+		Client:   client,
+		RPC:      opts,
+		Network:  *network,
+		Interval: *interval,
+		GroupBy:  strings.Split(*groupBy, ","),
+		MaxNodes: *maxNodes,
+		Chain:    *chain,
+		Extra:    metrics,
+	}
+	if err := e.Validate(); err != nil {
+		return err
+	}
+
+	go e.Run(context.Background(), func(err error) {
+		fmt.Fprintln(os.Stderr, "marmotctl exporter:", err)
+	})
+
+	http.Handle("/metrics", e)
+
+	fmt.Fprintf(os.Stderr, "serving metrics on http://%s/metrics\n", *listen)
+	return http.ListenAndServe(*listen, nil)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	marmotcoreclient "github.com/freddiecoleman/marmotcore-client"
+)
+
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"certs":    {"inspect node certificates and flag expiring or mismatched ones", runCerts},
+	"costs":    {"report node uptime costs from a pricing table", runCosts},
+	"delete":   {"delete nodes with confirmation and deletion protection", runDelete},
+	"exporter": {"serve fleet and chain metrics for Prometheus", runExporter},
+	"pins":     {"list, approve and revoke pinned node certificates", runPins},
+	"policy":   {"explain how the governance policy decides a create or delete", runPolicy},
+	"protect":  {"list, add and remove deletion-protected nodes and selectors", runProtect},
+	"proxy":    {"serve node RPC endpoints locally without mutual TLS", runProxy},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: marmotctl <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].summary)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "marmotctl:", err)
+		os.Exit(1)
+	}
+}
+
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	flags := flag.NewFlagSet("marmotctl "+name, flag.ExitOnError)
+	profile := flags.String("profile", "", "configuration profile (default $MARMOTCORE_PROFILE or default_profile)")
+	return flags, profile
+}
+
+func newClient(profile string) (marmotcoreclient.MarmotcoreClient, error) {
+	return marmotcoreclient.NewClientForProfile(profile)
+}
+
+// addRPCFlags registers the flags for connecting to node RPC endpoints and
+// returns a function building RPCOptions from them once parsed.
+func addRPCFlags(flags *flag.FlagSet) func() marmotcoreclient.RPCOptions {
+	defaultPath, _ := marmotcoreclient.DefaultTrustStorePath()
+
+	knownNodes := flags.String("known-nodes", defaultPath, "trust store of pinned node certificates")
+	autoApprove := flags.Bool("auto-approve", false, "trust node certificates on first contact without approval")
+	insecure := flags.Bool("insecure", false, "skip verifying node certificates")
+	timeout := flags.Duration("rpc-timeout", marmotcoreclient.DefaultTimeout, "timeout for node RPC calls")
+
+	return func() marmotcoreclient.RPCOptions {
+		opts := marmotcoreclient.RPCOptions{InsecureSkipVerify: *insecure, Timeout: *timeout}
+		if !*insecure {
+			opts.TrustStore = &marmotcoreclient.TrustStore{Path: *knownNodes, AutoApprove: *autoApprove}
+		}
+		return opts
+	}
+}
+
+// addVaultFlag registers -vault and returns a function that, once the flags
+// are parsed, sets opts to read node keys from the vault if it was asked for.
+func addVaultFlag(flags *flag.FlagSet) func(opts *marmotcoreclient.RPCOptions) error {
+	defaultVault, _ := marmotcoreclient.DefaultVaultPath()
+	vault := flags.Bool("vault", false, "read node keys from the vault ("+defaultVault+") before asking the API")
+
+	return func(opts *marmotcoreclient.RPCOptions) error {
+		if !*vault {
+			return nil
+		}
+
+		var err error
+		opts.Vault, err = marmotcoreclient.OpenVaultFromEnv(defaultVault)
+		return err
+	}
+}
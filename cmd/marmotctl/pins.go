@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	marmotcoreclient "github.com/freddiecoleman/marmotcore-client"
+)
+
+func runPins(args []string) error {
+	defaultPath, _ := marmotcoreclient.DefaultTrustStorePath()
+
+	flags := flag.NewFlagSet("marmotctl pins", flag.ExitOnError)
+	path := flags.String("file", defaultPath, "trust store file")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: marmotctl pins [-file path] list | approve <node-id> | revoke <node-id>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	store := &marmotcoreclient.TrustStore{Path: *path}
+
+	switch {
+	case flags.NArg() == 0 || flags.Arg(0) == "list":
+		return listPins(store)
+	case flags.NArg() == 2 && flags.Arg(0) == "approve":
+		return store.Approve(flags.Arg(1))
+	case flags.NArg() == 2 && flags.Arg(0) == "revoke":
+		return store.Revoke(flags.Arg(1))
+	}
+
+	flags.Usage()
+	return errors.New("invalid arguments")
+}
+
+func listPins(store *marmotcoreclient.TrustStore) error {
+	pins, err := store.Pins()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tPUBLIC IP\tSTATUS\tFIRST SEEN\tFINGERPRINT")
+	for _, pin := range pins {
+		status := "pending"
+		if pin.Approved {
+			status = "approved"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", pin.NodeId, pin.PublicIp, status, pin.FirstSeen.Format(time.RFC3339), pin.Fingerprint)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	marmotcoreclient "github.com/freddiecoleman/marmotcore-client"
+)
+
+func runPolicy(args []string) error {
+	flags, profileName := newFlagSet("policy")
+	file := flags.String("file", "", "policy file (default the profile's policy_file)")
+	region := flags.String("region", "", "region of the node to create (default from the profile)")
+	instanceType := flags.String("instance-type", "", "instance type of the node to create (default from the profile)")
+	chiaVersion := flags.String("chia-version", "", "Chia version of the node to create (default from the profile)")
+	network := flags.String("network", "", "network of the node to create (default from the profile)")
+	asJSON := flags.Bool("json", false, "print the decision as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: marmotctl policy [flags] create | delete <node-id>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	profile, err := marmotcoreclient.LoadProfile(*profileName)
+	if err != nil {
+		return err
+	}
+	if *file != "" {
+		profile.PolicyFile = *file
+	}
+	if profile.PolicyFile == "" {
+		return errors.New("no policy: pass -file or set policy_file in the profile")
+	}
+
+	mc, err := profile.Client()
+	if err != nil {
+		return err
+	}
+
+	var request marmotcoreclient.PolicyRequest
+	switch {
+	case flags.NArg() == 1 && flags.Arg(0) == "create":
+		createNode := profile.NewCreateNode()
+		overrides := []struct{ value, field *string }{
+			{region, &createNode.Region},
+			{instanceType, &createNode.InstanceType},
+			{chiaVersion, &createNode.ChiaVersion},
+			{network, &createNode.Network},
+		}
+		for _, override := range overrides {
+			if *override.value != "" {
+				*override.field = *override.value
+			}
+		}
+		request = marmotcoreclient.CreateNodeRequest(*createNode)
+	case flags.NArg() == 2 && flags.Arg(0) == "delete":
+		node, err := mc.GetNode(flags.Arg(1))
+		if err != nil {
+			return err
+		}
+		if node.Node.NodeId == "" {
+			return fmt.Errorf("%w: %s", marmotcoreclient.ErrNodeNotFound, flags.Arg(1))
+		}
+		request = marmotcoreclient.DeleteNodeRequest(node.Node)
+	default:
+		flags.Usage()
+		return errors.New("invalid arguments")
+	}
+
+	decision, err := mc.Policy.Evaluate(request)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		err = printJSON(decision)
+	} else {
+		_, err = fmt.Println(decision.Explain())
+	}
+	if err != nil {
+		return err
+	}
+
+	if !decision.Allowed {
+		return fmt.Errorf("%s would be denied", request.Operation)
+	}
+	return nil
+}
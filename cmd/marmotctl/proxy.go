@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	marmotcoreclient "github.com/freddiecoleman/marmotcore-client"
+)
+
+func runProxy(args []string) error {
+	flags, profile := newFlagSet("proxy")
+	listen := flags.String("listen", "127.0.0.1:8556", "address to listen on")
+	socket := flags.String("socket", "", "listen on this Unix socket instead of -listen")
+	allow := flags.String("allow", "", "comma separated endpoints to allow, * as a suffix matches by prefix (default get_*)")
+	allowAll := flags.Bool("allow-all", false, "allow every endpoint, including ones that change a node such as push_tx and stop_node")
+	allowRemote := flags.Bool("allow-remote", false, "listen on and serve addresses other than loopback; anyone who can reach the proxy can use the node keys")
+	rpcOptions := addRPCFlags(flags)
+	useVault := addVaultFlag(flags)
+	flags.Parse(args)
+
+	if *allowAll && *allow != "" {
+		return errors.New("-allow-all and -allow can't be combined")
+	}
+	if *socket == "" && !*allowRemote && !loopbackAddr(*listen) {
+		return fmt.Errorf("-listen %s is reachable from other machines, pass -allow-remote to listen on it anyway", *listen)
+	}
+
+	client, err := newClient(*profile)
+	if err != nil {
+		return err
+	}
+
+	opts := rpcOptions()
+	if err := useVault(&opts); err != nil {
+		return err
+	}
+
+	proxy := &marmotcoreclient.RPCProxy{Client: client, RPC: opts, AllowRemote: *allowRemote}
+	switch {
+	case *allowAll:
+		proxy.Allow = []string{"*"}
+	case *allow != "":
+		proxy.Allow = strings.Split(*allow, ",")
+	}
+
+	var listener net.Listener
+	if *socket != "" {
+		listener, err = listenUnix(*socket)
+	} else {
+		listener, err = net.Listen("tcp", *listen)
+	}
+	if err != nil {
+		return err
+	}
+
+	addr := listener.Addr().String()
+	if *socket != "" {
+		addr = *socket
+	}
+	fmt.Fprintf(os.Stderr, "proxying /nodes/{nodeId}/rpc/{endpoint} on %s\n", addr)
+	return http.Serve(listener, proxy)
+}
+
+// loopbackAddr reports whether a listen address only accepts connections
+// from this machine. An empty host listens on every interface.
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// listenUnix creates the socket inside a fresh 0700 directory, restricts it
+// to the owner and only then moves it into place, so it is never reachable
+// by other users. Whatever is at path is only replaced if it is a socket.
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".marmotctl-proxy-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "proxy.sock")
+	listener, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	err = os.Chmod(tmp, 0600)
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
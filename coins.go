@@ -0,0 +1,196 @@
+package marmotcoreclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+)
+
+type Coin struct {
+	ParentCoinInfo Bytes32    `json:"parent_coin_info"`
+	PuzzleHash     PuzzleHash `json:"puzzle_hash"`
+	Amount         uint64     `json:"amount"`
+}
+
+// ID is the coin's name: sha256 of the parent coin ID, puzzle hash and
+// amount, with the amount encoded as a minimal signed big endian integer.
+func (c Coin) ID() Bytes32 {
+	h := sha256.New()
+	h.Write(c.ParentCoinInfo[:])
+	h.Write(c.PuzzleHash[:])
+	h.Write(amountBytes(c.Amount))
+
+	var id Bytes32
+	copy(id[:], h.Sum(nil))
+	return id
+}
+
+func amountBytes(amount uint64) []byte {
+	var b []byte
+	for ; amount > 0; amount >>= 8 {
+		b = append([]byte{byte(amount)}, b...)
+	}
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return b
+}
+
+type CoinRecord struct {
+	Coin                Coin   `json:"coin"`
+	ConfirmedBlockIndex uint32 `json:"confirmed_block_index"`
+	SpentBlockIndex     uint32 `json:"spent_block_index"`
+	Spent               bool   `json:"spent"`
+	Coinbase            bool   `json:"coinbase"`
+	Timestamp           uint64 `json:"timestamp"`
+}
+
+// CoinQuery narrows a coin record lookup. Heights are confirmed block
+// heights, StartHeight inclusive and EndHeight exclusive; nil means
+// unbounded.
+type CoinQuery struct {
+	IncludeSpent bool
+	StartHeight  *uint32
+	EndHeight    *uint32
+}
+
+func (c *RPCClient) GetCoinRecordsByPuzzleHashes(ctx context.Context, puzzleHashes []PuzzleHash, query CoinQuery) ([]CoinRecord, error) {
+	request := struct {
+		PuzzleHashes      []PuzzleHash `json:"puzzle_hashes"`
+		StartHeight       uint32       `json:"start_height"`
+		EndHeight         uint32       `json:"end_height"`
+		IncludeSpentCoins bool         `json:"include_spent_coins"`
+	}{
+		PuzzleHashes:      puzzleHashes,
+		EndHeight:         math.MaxUint32,
+		IncludeSpentCoins: query.IncludeSpent,
+	}
+	if query.StartHeight != nil {
+		request.StartHeight = *query.StartHeight
+	}
+	if query.EndHeight != nil {
+		request.EndHeight = *query.EndHeight
+	}
+
+	var response struct {
+		CoinRecords []CoinRecord `json:"coin_records"`
+	}
+
+	err := c.Call(ctx, "get_coin_records_by_puzzle_hashes", request, &response)
+	return response.CoinRecords, err
+}
+
+// GetAdditionsAndRemovals returns the coins a block created and spent.
+func (c *RPCClient) GetAdditionsAndRemovals(ctx context.Context, headerHash Bytes32) ([]CoinRecord, []CoinRecord, error) {
+	var response struct {
+		Additions []CoinRecord `json:"additions"`
+		Removals  []CoinRecord `json:"removals"`
+	}
+
+	err := c.Call(ctx, "get_additions_and_removals", map[string]Bytes32{"header_hash": headerHash}, &response)
+	return response.Additions, response.Removals, err
+}
+
+type CoinSpend struct {
+	Coin         Coin   `json:"coin"`
+	PuzzleReveal string `json:"puzzle_reveal"`
+	Solution     string `json:"solution"`
+}
+
+// SpendBundle is a transaction as push_tx takes it. Puzzle reveals,
+// solutions and the signature are 0x-prefixed hex.
+type SpendBundle struct {
+	CoinSpends          []CoinSpend `json:"coin_spends"`
+	AggregatedSignature string      `json:"aggregated_signature"`
+}
+
+// Removals are the IDs of the coins the bundle spends.
+func (b SpendBundle) Removals() []Bytes32 {
+	removals := make([]Bytes32, 0, len(b.CoinSpends))
+	for _, spend := range b.CoinSpends {
+		removals = append(removals, spend.Coin.ID())
+	}
+	return removals
+}
+
+// Serialise encodes the bundle in Chia's streamable format.
+func (b SpendBundle) Serialise() ([]byte, error) {
+	var buf bytes.Buffer
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(b.CoinSpends)))
+	buf.Write(length[:])
+
+	for _, spend := range b.CoinSpends {
+		var amount [8]byte
+		binary.BigEndian.PutUint64(amount[:], spend.Coin.Amount)
+		buf.Write(spend.Coin.ParentCoinInfo[:])
+		buf.Write(spend.Coin.PuzzleHash[:])
+		buf.Write(amount[:])
+
+		for _, program := range []string{spend.PuzzleReveal, spend.Solution} {
+			decoded, err := decodeHex(program)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(decoded)
+		}
+	}
+
+	signature, err := decodeHex(b.AggregatedSignature)
+	if err != nil {
+		return nil, err
+	}
+	if len(signature) != 96 {
+		return nil, fmt.Errorf("expected a 96 byte signature, got %d", len(signature))
+	}
+	buf.Write(signature)
+
+	return buf.Bytes(), nil
+}
+
+// Name is the transaction ID: sha256 of the serialised bundle, as the
+// mempool endpoints report it.
+func (b SpendBundle) Name() (Bytes32, error) {
+	serialised, err := b.Serialise()
+	if err != nil {
+		return Bytes32{}, err
+	}
+	return Bytes32(sha256.Sum256(serialised)), nil
+}
+
+func decodeHex(s string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimPrefix(s, "0x"))
+}
+
+// GetCoinRecordsByNames looks coins up by their IDs.
+func (c *RPCClient) GetCoinRecordsByNames(ctx context.Context, names []Bytes32, query CoinQuery) ([]CoinRecord, error) {
+	request := struct {
+		Names             []Bytes32 `json:"names"`
+		StartHeight       uint32    `json:"start_height"`
+		EndHeight         uint32    `json:"end_height"`
+		IncludeSpentCoins bool      `json:"include_spent_coins"`
+	}{
+		Names:             names,
+		EndHeight:         math.MaxUint32,
+		IncludeSpentCoins: query.IncludeSpent,
+	}
+	if query.StartHeight != nil {
+		request.StartHeight = *query.StartHeight
+	}
+	if query.EndHeight != nil {
+		request.EndHeight = *query.EndHeight
+	}
+
+	var response struct {
+		CoinRecords []CoinRecord `json:"coin_records"`
+	}
+
+	err := c.Call(ctx, "get_coin_records_by_names", request, &response)
+	return response.CoinRecords, err
+}
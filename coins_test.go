@@ -0,0 +1,52 @@
+package marmotcoreclient
+
+import (
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAmountBytes(t *testing.T) {
+	assert.Empty(t, amountBytes(0))
+	assert.Equal(t, []byte{0x7f}, amountBytes(127))
+	assert.Equal(t, []byte{0x00, 0x80}, amountBytes(128))
+	assert.Equal(t, []byte{0x01, 0x00}, amountBytes(256))
+	assert.Equal(t, []byte{0x00, 0xe8, 0xd4, 0xa5, 0x10, 0x00}, amountBytes(MojoPerXCH))
+}
+
+func TestCoinID(t *testing.T) {
+	parent, _ := ParseBytes32(strings.Repeat("11", 32))
+	puzzleHash, _ := ParsePuzzleHash(strings.Repeat("22", 32))
+	coin := Coin{ParentCoinInfo: parent, PuzzleHash: puzzleHash, Amount: 1000}
+
+	message := append(append(append([]byte(nil), parent[:]...), puzzleHash[:]...), 0x03, 0xe8)
+	assert.Equal(t, Bytes32(sha256.Sum256(message)), coin.ID())
+}
+
+func TestSpendBundleName(t *testing.T) {
+	bundle := SpendBundle{
+		CoinSpends: []CoinSpend{{
+			Coin:         Coin{ParentCoinInfo: Bytes32{1}, PuzzleHash: PuzzleHash{2}, Amount: 1},
+			PuzzleReveal: "0x80",
+			Solution:     "0x80",
+		}},
+		AggregatedSignature: "0xc0" + strings.Repeat("00", 95),
+	}
+
+	serialised := []byte{0, 0, 0, 1}
+	serialised = append(serialised, bundle.CoinSpends[0].Coin.ParentCoinInfo[:]...)
+	serialised = append(serialised, bundle.CoinSpends[0].Coin.PuzzleHash[:]...)
+	serialised = append(serialised, 0, 0, 0, 0, 0, 0, 0, 1, 0x80, 0x80, 0xc0)
+	serialised = append(serialised, make([]byte, 95)...)
+
+	name, err := bundle.Name()
+	assert.NoError(t, err)
+	assert.Equal(t, Bytes32(sha256.Sum256(serialised)), name)
+	assert.Equal(t, []Bytes32{bundle.CoinSpends[0].Coin.ID()}, bundle.Removals())
+
+	bundle.AggregatedSignature = "0xc0"
+	_, err = bundle.Name()
+	assert.EqualError(t, err, "expected a 96 byte signature, got 1")
+}
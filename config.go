@@ -0,0 +1,246 @@
+package marmotcoreclient
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const DefaultTimeout = 10 * time.Second
+
+// Profile holds everything needed to talk to one MarmotCore endpoint.
+// Credentials is a reference rather than a secret: "env:NAME" or "file:PATH".
+type Profile struct {
+	Name        string              `yaml:"-"`
+	Endpoint    string              `yaml:"endpoint"`
+	Credentials string              `yaml:"credentials"`
+	Timeout     time.Duration       `yaml:"timeout"`
+	CreateNode  CreateNode          `yaml:"create_node"`
+	Guardrails  *Guardrails         `yaml:"guardrails"`
+	PolicyFile  string              `yaml:"policy_file"`
+	Protection  *DeletionProtection `yaml:"deletion_protection"`
+}
+
+type Config struct {
+	DefaultProfile string             `yaml:"default_profile"`
+	Profiles       map[string]Profile `yaml:"profiles"`
+}
+
+var ErrProfileNotFound = errors.New("marmotcore profile not found")
+
+// DefaultConfigPath is $MARMOTCORE_CONFIG if set, otherwise
+// $XDG_CONFIG_HOME/marmotcore/config.yaml, falling back to ~/.config.
+func DefaultConfigPath() (string, error) {
+	if path := os.Getenv("MARMOTCORE_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(dir, "marmotcore", "config.yaml"), nil
+}
+
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	return &config, nil
+}
+
+// Profile resolves a profile by name. An empty name selects
+// $MARMOTCORE_PROFILE, then default_profile, then "default". Values from
+// MARMOTCORE_* environment variables override those from the file, and
+// unset values fall back to the built-in defaults.
+func (c *Config) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = os.Getenv("MARMOTCORE_PROFILE")
+	}
+	if name == "" && c != nil {
+		name = c.DefaultProfile
+	}
+
+	explicit := name != ""
+	if !explicit {
+		name = "default"
+	}
+
+	var profile Profile
+	found := false
+	if c != nil {
+		profile, found = c.Profiles[name]
+	}
+
+	profile.Name = name
+	if err := profile.applyEnv(); err != nil {
+		return profile, err
+	}
+
+	if !found && (explicit || profile.Endpoint == "") {
+		return profile, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+
+	if profile.Timeout == 0 {
+		profile.Timeout = DefaultTimeout
+	}
+
+	return profile, nil
+}
+
+func (p *Profile) applyEnv() error {
+	overrides := map[string]*string{
+		"MARMOTCORE_ENDPOINT":      &p.Endpoint,
+		"MARMOTCORE_CREDENTIALS":   &p.Credentials,
+		"MARMOTCORE_REGION":        &p.CreateNode.Region,
+		"MARMOTCORE_INSTANCE_TYPE": &p.CreateNode.InstanceType,
+		"MARMOTCORE_CHIA_VERSION":  &p.CreateNode.ChiaVersion,
+		"MARMOTCORE_NETWORK":       &p.CreateNode.Network,
+	}
+	for name, field := range overrides {
+		if value := os.Getenv(name); value != "" {
+			*field = value
+		}
+	}
+
+	if value := os.Getenv("MARMOTCORE_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("MARMOTCORE_TIMEOUT: %w", err)
+		}
+		p.Timeout = timeout
+	}
+
+	return nil
+}
+
+// Client builds a MarmotcoreClient from the profile's endpoint, e.g.
+// https://api.marmotcore.com/v1, with its own HTTP client and timeout.
+func (p Profile) Client() (MarmotcoreClient, error) {
+	var mc MarmotcoreClient
+
+	endpoint, err := url.Parse(p.Endpoint)
+	if err != nil {
+		return mc, fmt.Errorf("profile %s: invalid endpoint: %w", p.Name, err)
+	}
+	if endpoint.Scheme == "" || endpoint.Hostname() == "" {
+		return mc, fmt.Errorf("profile %s: invalid endpoint %q", p.Name, p.Endpoint)
+	}
+
+	port := endpoint.Port()
+	if port == "" {
+		port = "80"
+		if endpoint.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	var policy *Policy
+	if p.PolicyFile != "" {
+		loaded, err := LoadPolicy(p.PolicyFile)
+		if err != nil {
+			return mc, fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+		policy = loaded.forProfile(p.Name)
+	}
+
+	return MarmotcoreClient{
+		Protocol:   endpoint.Scheme,
+		Host:       endpoint.Hostname(),
+		Port:       port,
+		ApiVersion: strings.Trim(endpoint.Path, "/"),
+		HTTPClient: &http.Client{Timeout: timeout},
+		Guardrails: p.Guardrails,
+		Policy:     policy,
+		Protection: p.Protection,
+	}, nil
+}
+
+// NewCreateNode returns a copy of the profile's CreateNode template.
+func (p Profile) NewCreateNode() *CreateNode {
+	createNode := p.CreateNode
+	return &createNode
+}
+
+// ResolveCredentials follows the profile's credentials reference.
+func (p Profile) ResolveCredentials() (string, error) {
+	value, err := resolveReference(p.Credentials)
+	if err != nil {
+		return "", fmt.Errorf("profile %s: credentials: %w", p.Name, err)
+	}
+	return value, nil
+}
+
+// resolveReference reads the value an "env:NAME" or "file:PATH" reference
+// points to. An empty reference resolves to an empty value.
+func resolveReference(ref string) (string, error) {
+	switch {
+	case ref == "":
+		return "", nil
+	case strings.HasPrefix(ref, "env:"):
+		name := strings.TrimPrefix(ref, "env:")
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("variable %s is not set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, "file:"):
+		data, err := ioutil.ReadFile(strings.TrimPrefix(ref, "file:"))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	return "", fmt.Errorf("unsupported reference %q", ref)
+}
+
+// LoadProfile reads the default config file, if there is one, and resolves
+// the named profile from it and the environment.
+func LoadProfile(name string) (Profile, error) {
+	path, err := DefaultConfigPath()
+	if err != nil {
+		return Profile{}, err
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Profile{}, err
+	}
+
+	return config.Profile(name)
+}
+
+func NewClientForProfile(name string) (MarmotcoreClient, error) {
+	profile, err := LoadProfile(name)
+	if err != nil {
+		return MarmotcoreClient{}, err
+	}
+
+	return profile.Client()
+}
@@ -0,0 +1,112 @@
+package marmotcoreclient
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfig = `
+default_profile: staging
+profiles:
+  prod:
+    endpoint: https://api.marmotcore.com/v1
+    credentials: env:MARMOTCORE_PROD_TOKEN
+    timeout: 30s
+    create_node:
+      region: us-west-2
+      instance_type: node.small
+      chia_version: 1.3.*
+      network: mainnet
+  staging:
+    endpoint: https://staging.marmotcore.com:8443/v1
+  local:
+    endpoint: http://localhost:3000/v1
+    timeout: 1s
+`
+
+func writeTestConfig(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(testConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MARMOTCORE_CONFIG", path)
+	return path
+}
+
+func TestLoadConfigProfile(t *testing.T) {
+	config, err := LoadConfig(writeTestConfig(t))
+	assert.NoError(t, err)
+
+	profile, err := config.Profile("prod")
+	assert.NoError(t, err)
+	assert.Equal(t, "prod", profile.Name)
+	assert.Equal(t, 30*time.Second, profile.Timeout)
+	assert.Equal(t, CreateNode{Region: "us-west-2", InstanceType: "node.small", ChiaVersion: "1.3.*", Network: "mainnet"}, *profile.NewCreateNode())
+
+	mc, err := profile.Client()
+	assert.NoError(t, err)
+	assert.Equal(t, "https://api.marmotcore.com:443/v1", mc.url())
+	assert.Equal(t, 30*time.Second, mc.HTTPClient.(*http.Client).Timeout)
+}
+
+func TestProfileSelectionAndPrecedence(t *testing.T) {
+	writeTestConfig(t)
+
+	profile, err := LoadProfile("")
+	assert.NoError(t, err)
+	assert.Equal(t, "staging", profile.Name)
+	assert.Equal(t, DefaultTimeout, profile.Timeout)
+
+	t.Setenv("MARMOTCORE_PROFILE", "local")
+	t.Setenv("MARMOTCORE_TIMEOUT", "5s")
+	t.Setenv("MARMOTCORE_NETWORK", "testnet")
+
+	mc, err := NewClientForProfile("")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:3000/v1", mc.url())
+	assert.Equal(t, 5*time.Second, mc.HTTPClient.(*http.Client).Timeout)
+
+	profile, err = LoadProfile("prod")
+	assert.NoError(t, err)
+	assert.Equal(t, "testnet", profile.CreateNode.Network)
+
+	_, err = LoadProfile("missing")
+	assert.True(t, errors.Is(err, ErrProfileNotFound))
+}
+
+func TestProfileFromEnvironmentOnly(t *testing.T) {
+	t.Setenv("MARMOTCORE_CONFIG", filepath.Join(t.TempDir(), "missing.yaml"))
+	t.Setenv("MARMOTCORE_ENDPOINT", "http://127.0.0.1:3000/v1")
+
+	mc, err := NewClientForProfile("")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:3000/v1", mc.url())
+
+	t.Setenv("MARMOTCORE_ENDPOINT", "")
+	_, err = NewClientForProfile("")
+	assert.True(t, errors.Is(err, ErrProfileNotFound))
+}
+
+func TestResolveCredentials(t *testing.T) {
+	t.Setenv("MARMOTCORE_PROD_TOKEN", "secret-token")
+
+	token, err := Profile{Credentials: "env:MARMOTCORE_PROD_TOKEN"}.ResolveCredentials()
+	assert.NoError(t, err)
+	assert.Equal(t, "secret-token", token)
+
+	path := filepath.Join(t.TempDir(), "token")
+	ioutil.WriteFile(path, []byte("file-token\n"), 0600)
+
+	token, err = Profile{Credentials: "file:" + path}.ResolveCredentials()
+	assert.NoError(t, err)
+	assert.Equal(t, "file-token", token)
+
+	_, err = Profile{Credentials: "vault:prod"}.ResolveCredentials()
+	assert.Error(t, err)
+}
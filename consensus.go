@@ -0,0 +1,357 @@
+package marmotcoreclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const DefaultLagThreshold = 3
+
+var ErrNoQuorum = errors.New("nodes did not reach quorum")
+
+// NodePeak is one node's view of the chain. Error is set when the node
+// could not be queried.
+type NodePeak struct {
+	NodeId     string  `json:"node_id"`
+	Height     uint32  `json:"height"`
+	HeaderHash Bytes32 `json:"header_hash"`
+	Synced     bool    `json:"synced"`
+	Error      string  `json:"error,omitempty"`
+}
+
+type Laggard struct {
+	NodeId string `json:"node_id"`
+	Behind uint32 `json:"behind"`
+}
+
+// ConsensusReport compares the peaks of every running node on a network.
+// Divergent nodes disagree with the majority about the block at a height
+// they share; laggards are more than the lag threshold behind the highest
+// peak; stalled nodes have not moved their peak for StallAfter.
+type ConsensusReport struct {
+	Network     string     `json:"network"`
+	Height      uint32     `json:"height"`
+	Peaks       []NodePeak `json:"peaks"`
+	Agreeing    []string   `json:"agreeing"`
+	Divergent   []string   `json:"divergent"`
+	Laggards    []Laggard  `json:"laggards"`
+	Stalled     []string   `json:"stalled"`
+	Unreachable []string   `json:"unreachable"`
+}
+
+// Agreed is true when every node answered and none diverged, lagged or
+// stalled.
+func (r ConsensusReport) Agreed() bool {
+	return len(r.Divergent) == 0 && len(r.Laggards) == 0 && len(r.Stalled) == 0 && len(r.Unreachable) == 0
+}
+
+// ConsensusChecker compares the running nodes of Network. It remembers
+// peaks between checks to report stalled nodes.
+type ConsensusChecker struct {
+	Client       MarmotcoreClient
+	RPC          RPCOptions
+	Network      string
+	LagThreshold uint32
+	StallAfter   time.Duration
+
+	mu       sync.Mutex
+	progress map[string]peakProgress
+}
+
+type peakProgress struct {
+	height uint32
+	since  time.Time
+}
+
+type consensusNode struct {
+	peak NodePeak
+	rpc  *RPCClient
+}
+
+func (c *ConsensusChecker) clients() (map[string]*RPCClient, []string, error) {
+	if c.Network == "" {
+		return nil, nil, fmt.Errorf("consensus checker: %w", ErrNetworkRequired)
+	}
+
+	nodes, err := c.Client.RunningNodes(c.Network)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, nil, fmt.Errorf("%w on %s", ErrNoRunningNode, c.Network)
+	}
+
+	clients := map[string]*RPCClient{}
+	var unreachable []string
+	for _, node := range nodes {
+		rpc, err := c.Client.RPCClientForNode(node, c.RPC)
+		if err != nil {
+			unreachable = append(unreachable, node.NodeId)
+			continue
+		}
+		clients[node.NodeId] = rpc
+	}
+
+	return clients, unreachable, nil
+}
+
+// closeAll closes every client in clients.
+func closeAll(clients map[string]*RPCClient) {
+	for _, rpc := range clients {
+		rpc.Close()
+	}
+}
+
+// each calls fn on every client concurrently.
+func each(clients map[string]*RPCClient, fn func(nodeId string, rpc *RPCClient)) {
+	var wg sync.WaitGroup
+	for nodeId, rpc := range clients {
+		wg.Add(1)
+		go func(nodeId string, rpc *RPCClient) {
+			defer wg.Done()
+			fn(nodeId, rpc)
+		}(nodeId, rpc)
+	}
+	wg.Wait()
+}
+
+func (c *ConsensusChecker) Check(ctx context.Context) (ConsensusReport, error) {
+	report := ConsensusReport{Network: c.Network}
+
+	clients, unreachable, err := c.clients()
+	if err != nil {
+		return report, err
+	}
+	defer closeAll(clients)
+	for _, nodeId := range unreachable {
+		report.Peaks = append(report.Peaks, NodePeak{NodeId: nodeId, Error: "no RPC client"})
+	}
+
+	var mu sync.Mutex
+	nodes := map[string]*consensusNode{}
+	each(clients, func(nodeId string, rpc *RPCClient) {
+		peak := NodePeak{NodeId: nodeId}
+		state, err := rpc.GetBlockchainState(ctx)
+		switch {
+		case err != nil:
+			peak.Error = err.Error()
+		case state.Peak == nil:
+			peak.Error = "node has no peak"
+		default:
+			peak.Height = state.Peak.Height
+			peak.HeaderHash = state.Peak.HeaderHash
+			peak.Synced = state.Sync.Synced
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		report.Peaks = append(report.Peaks, peak)
+		if peak.Error == "" {
+			nodes[nodeId] = &consensusNode{peak: peak, rpc: rpc}
+		}
+	})
+
+	sort.Slice(report.Peaks, func(i, j int) bool { return report.Peaks[i].NodeId < report.Peaks[j].NodeId })
+	for _, peak := range report.Peaks {
+		if peak.Error != "" {
+			report.Unreachable = append(report.Unreachable, peak.NodeId)
+		}
+		if peak.Height > report.Height {
+			report.Height = peak.Height
+		}
+	}
+	if len(nodes) == 0 {
+		return report, nil
+	}
+
+	threshold := c.LagThreshold
+	if threshold == 0 {
+		threshold = DefaultLagThreshold
+	}
+
+	// Nodes close to the tip are compared at the lowest of their peaks, so
+	// a single far-behind node doesn't hide a recent fork.
+	compareAt := report.Height
+	var current []string
+	for _, nodeId := range sortedKeys(nodes) {
+		peak := nodes[nodeId].peak
+		if report.Height-peak.Height > threshold {
+			report.Laggards = append(report.Laggards, Laggard{NodeId: nodeId, Behind: report.Height - peak.Height})
+			continue
+		}
+		current = append(current, nodeId)
+		if peak.Height < compareAt {
+			compareAt = peak.Height
+		}
+	}
+
+	hashes := map[string]Bytes32{}
+	failed := map[string]error{}
+	each(subset(nodes, current), func(nodeId string, rpc *RPCClient) {
+		hash, err := c.hashAt(ctx, nodes[nodeId], compareAt)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err == nil {
+			hashes[nodeId] = hash
+		} else {
+			failed[nodeId] = err
+		}
+	})
+
+	// A node that can't serve the block being compared can't be counted
+	// either way, so it is reported unreachable.
+	if len(failed) > 0 {
+		for i, peak := range report.Peaks {
+			if err, ok := failed[peak.NodeId]; ok {
+				report.Peaks[i].Error = fmt.Sprintf("reading block %d: %s", compareAt, err)
+				report.Unreachable = append(report.Unreachable, peak.NodeId)
+			}
+		}
+		sort.Strings(report.Unreachable)
+	}
+
+	majority, agreeing := majorityHash(hashes)
+	report.Agreeing = agreeing
+	for _, nodeId := range current {
+		if hash, ok := hashes[nodeId]; ok && hash != majority {
+			report.Divergent = append(report.Divergent, nodeId)
+		}
+	}
+
+	// Laggards are checked against a majority node at their own height.
+	if len(agreeing) > 0 {
+		reference := nodes[agreeing[0]]
+		for _, laggard := range report.Laggards {
+			peak := nodes[laggard.NodeId].peak
+			block, err := reference.rpc.GetBlockRecordByHeight(ctx, peak.Height)
+			if err == nil && block.HeaderHash != peak.HeaderHash {
+				report.Divergent = append(report.Divergent, laggard.NodeId)
+			}
+		}
+		sort.Strings(report.Divergent)
+	}
+
+	report.Stalled = c.stalled(report.Peaks, time.Now())
+
+	return report, nil
+}
+
+func subset(nodes map[string]*consensusNode, nodeIds []string) map[string]*RPCClient {
+	clients := map[string]*RPCClient{}
+	for _, nodeId := range nodeIds {
+		clients[nodeId] = nodes[nodeId].rpc
+	}
+	return clients
+}
+
+func (c *ConsensusChecker) hashAt(ctx context.Context, node *consensusNode, height uint32) (Bytes32, error) {
+	if node.peak.Height == height {
+		return node.peak.HeaderHash, nil
+	}
+
+	block, err := node.rpc.GetBlockRecordByHeight(ctx, height)
+	return block.HeaderHash, err
+}
+
+// majorityHash returns the hash most nodes agree on and those nodes,
+// breaking ties by the lowest hash so the result is stable.
+func majorityHash(hashes map[string]Bytes32) (Bytes32, []string) {
+	groups := map[Bytes32][]string{}
+	for _, nodeId := range sortedKeys(hashes) {
+		groups[hashes[nodeId]] = append(groups[hashes[nodeId]], nodeId)
+	}
+
+	var majority Bytes32
+	var agreeing []string
+	for hash, nodeIds := range groups {
+		if len(nodeIds) > len(agreeing) || (len(nodeIds) == len(agreeing) && hash.String() < majority.String()) {
+			majority = hash
+			agreeing = nodeIds
+		}
+	}
+
+	return majority, agreeing
+}
+
+func (c *ConsensusChecker) stalled(peaks []NodePeak, now time.Time) []string {
+	if c.StallAfter == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.progress == nil {
+		c.progress = map[string]peakProgress{}
+	}
+
+	var stalled []string
+	for _, peak := range peaks {
+		if peak.Error != "" {
+			continue
+		}
+
+		last, ok := c.progress[peak.NodeId]
+		if !ok || peak.Height != last.height {
+			c.progress[peak.NodeId] = peakProgress{height: peak.Height, since: now}
+			continue
+		}
+		if now.Sub(last.since) >= c.StallAfter {
+			stalled = append(stalled, peak.NodeId)
+		}
+	}
+
+	return stalled
+}
+
+// MajorityRead calls endpoint on every running node and decodes into
+// response the reply at least quorum nodes returned identically. Replies
+// are compared after normalising their JSON, so key order doesn't matter.
+// quorum has to be at least 1.
+func (c *ConsensusChecker) MajorityRead(ctx context.Context, endpoint string, request interface{}, response interface{}, quorum int) error {
+	if quorum < 1 {
+		return fmt.Errorf("quorum must be at least 1, not %d", quorum)
+	}
+
+	clients, _, err := c.clients()
+	if err != nil {
+		return err
+	}
+	defer closeAll(clients)
+
+	var mu sync.Mutex
+	votes := map[string]int{}
+	each(clients, func(nodeId string, rpc *RPCClient) {
+		var reply interface{}
+		if err := rpc.Call(ctx, endpoint, request, &reply); err != nil {
+			return
+		}
+		normalised, err := json.Marshal(reply)
+		if err != nil {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		votes[string(normalised)]++
+	})
+
+	best, count := "", 0
+	for _, reply := range sortedKeys(votes) {
+		if votes[reply] > count {
+			best, count = reply, votes[reply]
+		}
+	}
+
+	if count < quorum {
+		return fmt.Errorf("%w for %s: %d of %d nodes agreed, needed %d", ErrNoQuorum, endpoint, count, len(clients), quorum)
+	}
+
+	return json.Unmarshal([]byte(best), response)
+}
@@ -0,0 +1,169 @@
+package marmotcoreclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestFleet serves one chain per node from a single test server, told
+// apart by the client certificate each node's key presents. Nodes with an
+// empty chain answer every call with an error. Endpoints other than the
+// block ones go to handle, when given, and fail if it returns nil.
+func newTestFleet(t *testing.T, chains map[string][]BlockRecord, handle func(nodeId string, endpoint string, request []byte) interface{}) ConsensusChecker {
+	var nodes []Node
+	var keys []Key
+	serials := map[string]string{}
+
+	for _, nodeId := range sortedKeys(chains) {
+		nodes = append(nodes, *newNode("testUserId", 1648394251715, nodeId, "127.0.0.1", "us-west-2", "node.small", "1.3.*", "testnet", "R", false))
+		key := newTestKeyPair(t, nodeId, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+		keys = append(keys, key)
+
+		cert, _ := ParseCert(key.Cert)
+		serials[cert.SerialNumber.String()] = nodeId
+	}
+	nodes = append(nodes, *newNode("testUserId", 1648394251715, "keyless", "127.0.0.1", "us-west-2", "node.small", "1.3.*", "testnet", "R", false))
+	mockNodesAndKeys(t, nodes, keys)
+
+	_, port := newTestRPCHandlerServer(t, func(endpoint string, request []byte, client *x509.Certificate) string {
+		nodeId := serials[client.SerialNumber.String()]
+		chain := chains[nodeId]
+		if len(chain) == 0 {
+			return `{"success":false,"error":"not ready"}`
+		}
+
+		var reply interface{}
+		switch endpoint {
+		case "get_blockchain_state":
+			state := BlockchainState{Peak: &chain[len(chain)-1], Sync: SyncState{Synced: true}, NodeId: testPeerId(nodeId).String()}
+			reply = map[string]interface{}{"blockchain_state": state}
+		case "get_block_record_by_height":
+			var params struct{ Height uint32 }
+			json.Unmarshal(request, &params)
+			if int(params.Height) >= len(chain) {
+				return `{"success":false,"error":"not found"}`
+			}
+			reply = map[string]interface{}{"block_record": chain[params.Height]}
+		default:
+			if handle != nil {
+				reply = handle(nodeId, endpoint, request)
+			}
+			if reply == nil {
+				return `{"success":false,"error":"unknown endpoint"}`
+			}
+		}
+
+		body, _ := json.Marshal(reply)
+		if string(body) == "{}" {
+			return `{"success":true}`
+		}
+		return `{"success":true,` + string(body[1:])
+	})
+
+	return ConsensusChecker{
+		Client:  MarmotcoreClient{Protocol: "http", Host: "localhost", Port: "3000", ApiVersion: "v1"},
+		RPC:     RPCOptions{Port: port, InsecureSkipVerify: true},
+		Network: "testnet",
+	}
+}
+
+// testPeerId is the Chia node ID a test fleet node reports.
+func testPeerId(nodeId string) Bytes32 {
+	return Bytes32(sha256.Sum256([]byte(nodeId)))
+}
+
+// networkNameHandler answers get_network_info with a name per node.
+func networkNameHandler(names map[string]string) func(string, string, []byte) interface{} {
+	return func(nodeId string, endpoint string, request []byte) interface{} {
+		if endpoint != "get_network_info" {
+			return nil
+		}
+		return map[string]interface{}{"network_prefix": "txch", "network_name": names[nodeId]}
+	}
+}
+
+func TestConsensusCheck(t *testing.T) {
+	main := testChain(1, 0, 10, Bytes32{})
+	chains := map[string][]BlockRecord{
+		"a": main,
+		"b": main,
+		"c": append(append([]BlockRecord(nil), main[:9]...), testChain(2, 9, 10, main[8].HeaderHash)...),
+		"d": main[:6],
+		"e": testChain(3, 0, 4, Bytes32{}),
+	}
+
+	checker := newTestFleet(t, chains, nil)
+	checker.StallAfter = time.Nanosecond
+
+	report, err := checker.Check(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(10), report.Height)
+	assert.Len(t, report.Peaks, 6)
+	assert.Equal(t, []string{"a", "b"}, report.Agreeing)
+	assert.Equal(t, []string{"c", "e"}, report.Divergent)
+	assert.Equal(t, []Laggard{{NodeId: "d", Behind: 5}, {NodeId: "e", Behind: 6}}, report.Laggards)
+	assert.Equal(t, []string{"keyless"}, report.Unreachable)
+	assert.Empty(t, report.Stalled)
+	assert.False(t, report.Agreed())
+
+	report, err = checker.Check(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, report.Stalled)
+}
+
+func TestConsensusCheckUnreadableBlock(t *testing.T) {
+	main := testChain(1, 0, 10, Bytes32{})
+	chains := map[string][]BlockRecord{
+		"a": main,
+		"b": main,
+		"g": main[:10],
+		// Only holds its last two blocks, so it can't serve height 9.
+		"f": main[9:],
+	}
+
+	checker := newTestFleet(t, chains, nil)
+	report, err := checker.Check(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "g"}, report.Agreeing)
+	assert.Empty(t, report.Divergent)
+	assert.Equal(t, []string{"f", "keyless"}, report.Unreachable)
+	assert.Contains(t, report.Peaks[2].Error, "reading block 9")
+	assert.False(t, report.Agreed())
+}
+
+func TestMajorityRead(t *testing.T) {
+	chains := map[string][]BlockRecord{"a": testChain(1, 0, 1, Bytes32{}), "b": testChain(1, 0, 1, Bytes32{}), "c": testChain(1, 0, 1, Bytes32{})}
+	checker := newTestFleet(t, chains, networkNameHandler(map[string]string{"a": "testnet10", "b": "testnet10", "c": "forked"}))
+
+	var info struct {
+		NetworkName string `json:"network_name"`
+	}
+	err := checker.MajorityRead(context.Background(), "get_network_info", nil, &info, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "testnet10", info.NetworkName)
+
+	err = checker.MajorityRead(context.Background(), "get_network_info", nil, &info, 3)
+	assert.True(t, errors.Is(err, ErrNoQuorum))
+	assert.EqualError(t, err, "nodes did not reach quorum for get_network_info: 2 of 3 nodes agreed, needed 3")
+
+	assert.EqualError(t, checker.MajorityRead(context.Background(), "get_network_info", nil, &info, 0), "quorum must be at least 1, not 0")
+	err = checker.MajorityRead(context.Background(), "unknown", nil, &info, 1)
+	assert.True(t, errors.Is(err, ErrNoQuorum))
+}
+
+func TestConsensusCheckerRequiresNetwork(t *testing.T) {
+	checker := newTestFleet(t, map[string][]BlockRecord{"a": testChain(1, 0, 1, Bytes32{})}, nil)
+	checker.Network = ""
+
+	_, err := checker.Check(context.Background())
+	assert.ErrorIs(t, err, ErrNetworkRequired)
+}
@@ -0,0 +1,365 @@
+package marmotcoreclient
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HoursPerMonth is the average month used for projections (8760 / 12).
+const HoursPerMonth = 730.0
+
+var ErrNoPrice = errors.New("no price for instance type")
+
+// Price is the hourly price of an instance type. An empty Region applies to
+// every region without a more specific price.
+type Price struct {
+	Region       string  `yaml:"region,omitempty" json:"region,omitempty"`
+	InstanceType string  `yaml:"instance_type" json:"instance_type"`
+	Hourly       float64 `yaml:"hourly" json:"hourly"`
+}
+
+// PricingTable is loaded from a YAML or JSON file such as:
+//
+//	currency: USD
+//	prices:
+//	  - instance_type: node.small
+//	    hourly: 0.05
+//	  - instance_type: node.small
+//	    region: eu-west-1
+//	    hourly: 0.056
+type PricingTable struct {
+	Currency string  `yaml:"currency" json:"currency"`
+	Prices   []Price `yaml:"prices" json:"prices"`
+}
+
+func LoadPricingTable(path string) (*PricingTable, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var table PricingTable
+	if err := yaml.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	for _, price := range table.Prices {
+		if price.InstanceType == "" {
+			return nil, fmt.Errorf("parsing %s: price without an instance_type", path)
+		}
+		if price.Hourly < 0 {
+			return nil, fmt.Errorf("parsing %s: negative price for %s", path, price.InstanceType)
+		}
+	}
+
+	return &table, nil
+}
+
+// Hourly returns the price for an instance type in a region, preferring a
+// region-specific price over the instance type's default.
+func (t *PricingTable) Hourly(region, instanceType string) (float64, error) {
+	found := false
+	var hourly float64
+
+	for _, price := range t.Prices {
+		if price.InstanceType != instanceType {
+			continue
+		}
+		if price.Region == region {
+			return price.Hourly, nil
+		}
+		if price.Region == "" {
+			hourly, found = price.Hourly, true
+		}
+	}
+
+	if !found {
+		return 0, fmt.Errorf("%w: %s in %s", ErrNoPrice, instanceType, region)
+	}
+	return hourly, nil
+}
+
+// NodeCost is what one node cost within a billing window. Start and End are
+// the part of the window the node was running for.
+type NodeCost struct {
+	NodeId           string    `json:"node_id"`
+	Region           string    `json:"region"`
+	InstanceType     string    `json:"instance_type"`
+	Network          string    `json:"network"`
+	Deleted          bool      `json:"deleted"`
+	Start            time.Time `json:"start"`
+	End              time.Time `json:"end"`
+	Hours            float64   `json:"hours"`
+	Hourly           float64   `json:"hourly"`
+	Cost             float64   `json:"cost"`
+	ProjectedMonthly float64   `json:"projected_monthly"`
+}
+
+// UnbilledNode is a node in the window that couldn't be costed.
+type UnbilledNode struct {
+	NodeId string `json:"node_id"`
+	Reason string `json:"reason"`
+}
+
+type CostReport struct {
+	Currency         string         `json:"currency"`
+	Start            time.Time      `json:"start"`
+	End              time.Time      `json:"end"`
+	Nodes            []NodeCost     `json:"nodes"`
+	Unbilled         []UnbilledNode `json:"unbilled,omitempty"`
+	Total            float64        `json:"total"`
+	ProjectedMonthly float64        `json:"projected_monthly"`
+}
+
+// Estimate costs every node that ran between start and end, including nodes
+// deleted since. Nodes still running at end are projected forward for a
+// month at their hourly price; pass time.Now() as end for cost to date.
+func (t *PricingTable) Estimate(nodes []Node, start, end time.Time) CostReport {
+	report := CostReport{Currency: t.Currency, Start: start, End: end}
+
+	for _, node := range nodes {
+		from := fromMillis(node.CreatedTime)
+		to := end
+		if node.Deleted {
+			if node.DeletedTime == 0 {
+				report.Unbilled = append(report.Unbilled, UnbilledNode{NodeId: node.NodeId, Reason: "deleted without a deleted_time"})
+				continue
+			}
+			if deleted := fromMillis(int64(node.DeletedTime)); deleted.Before(end) {
+				to = deleted
+			}
+		}
+		if from.Before(start) {
+			from = start
+		}
+		if !to.After(from) {
+			continue
+		}
+
+		hourly, err := t.Hourly(node.Region, node.InstanceType)
+		if err != nil {
+			report.Unbilled = append(report.Unbilled, UnbilledNode{NodeId: node.NodeId, Reason: err.Error()})
+			continue
+		}
+
+		cost := NodeCost{
+			NodeId:       node.NodeId,
+			Region:       node.Region,
+			InstanceType: node.InstanceType,
+			Network:      node.Network,
+			Deleted:      node.Deleted,
+			Start:        from,
+			End:          to,
+			Hours:        to.Sub(from).Hours(),
+			Hourly:       hourly,
+		}
+		cost.Cost = cost.Hours * hourly
+		if !node.Deleted {
+			cost.ProjectedMonthly = hourly * HoursPerMonth
+		}
+
+		report.Nodes = append(report.Nodes, cost)
+		report.Total += cost.Cost
+		report.ProjectedMonthly += cost.ProjectedMonthly
+	}
+
+	sort.Slice(report.Nodes, func(i, j int) bool {
+		return report.Nodes[i].NodeId < report.Nodes[j].NodeId
+	})
+
+	return report
+}
+
+// Costs fetches every node, deleted ones included, and costs them for the
+// window.
+func (mc MarmotcoreClient) Costs(pricing *PricingTable, start, end time.Time) (CostReport, error) {
+	nodes, err := mc.fleetNodes()
+	if err != nil {
+		return CostReport{}, err
+	}
+
+	return pricing.Estimate(nodes, start, end), nil
+}
+
+type CostDimension string
+
+const (
+	ByRegion       CostDimension = "region"
+	ByInstanceType CostDimension = "instance_type"
+	ByNetwork      CostDimension = "network"
+	ByDay          CostDimension = "day"
+	ByMonth        CostDimension = "month"
+)
+
+func ParseCostDimension(name string) (CostDimension, error) {
+	switch dimension := CostDimension(name); dimension {
+	case ByRegion, ByInstanceType, ByNetwork, ByDay, ByMonth:
+		return dimension, nil
+	}
+	return "", fmt.Errorf("unknown cost dimension %q", name)
+}
+
+type CostGroup struct {
+	Key              string  `json:"key"`
+	Nodes            int     `json:"nodes"`
+	Hours            float64 `json:"hours"`
+	Cost             float64 `json:"cost"`
+	ProjectedMonthly float64 `json:"projected_monthly"`
+}
+
+// GroupBy aggregates the report. By day or month, each node's uptime is
+// split at period boundaries (UTC) and the projection is left out, since it
+// doesn't belong to any one period.
+func (r CostReport) GroupBy(dimension CostDimension) []CostGroup {
+	groups := map[string]*CostGroup{}
+	group := func(key string) *CostGroup {
+		if groups[key] == nil {
+			groups[key] = &CostGroup{Key: key}
+		}
+		return groups[key]
+	}
+
+	for _, cost := range r.Nodes {
+		var key string
+		switch dimension {
+		case ByRegion:
+			key = cost.Region
+		case ByInstanceType:
+			key = cost.InstanceType
+		case ByNetwork:
+			key = cost.Network
+		case ByDay, ByMonth:
+			for _, period := range splitPeriods(cost.Start, cost.End, dimension) {
+				g := group(period.key)
+				hours := period.end.Sub(period.start).Hours()
+				g.Nodes++
+				g.Hours += hours
+				g.Cost += hours * cost.Hourly
+			}
+			continue
+		}
+
+		g := group(key)
+		g.Nodes++
+		g.Hours += cost.Hours
+		g.Cost += cost.Cost
+		g.ProjectedMonthly += cost.ProjectedMonthly
+	}
+
+	result := make([]CostGroup, 0, len(groups))
+	for _, key := range sortedKeys(groups) {
+		result = append(result, *groups[key])
+	}
+	return result
+}
+
+type costPeriod struct {
+	key        string
+	start, end time.Time
+}
+
+func splitPeriods(start, end time.Time, dimension CostDimension) []costPeriod {
+	var periods []costPeriod
+
+	for from := start.UTC(); from.Before(end); {
+		var next time.Time
+		var key string
+		if dimension == ByDay {
+			next = time.Date(from.Year(), from.Month(), from.Day()+1, 0, 0, 0, 0, time.UTC)
+			key = from.Format("2006-01-02")
+		} else {
+			next = time.Date(from.Year(), from.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			key = from.Format("2006-01")
+		}
+		if next.After(end) {
+			next = end
+		}
+
+		periods = append(periods, costPeriod{key: key, start: from, end: next})
+		from = next
+	}
+
+	return periods
+}
+
+func (r CostReport) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tREGION\tINSTANCE TYPE\tNETWORK\tSTART\tEND\tHOURS\tHOURLY\tCOST\tMONTHLY")
+	for _, cost := range r.Nodes {
+		end := cost.End.UTC().Format(time.RFC3339)
+		if cost.Deleted {
+			end += " (deleted)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%.1f\t%.4f\t%.2f\t%.2f\n",
+			cost.NodeId, cost.Region, cost.InstanceType, cost.Network,
+			cost.Start.UTC().Format(time.RFC3339), end,
+			cost.Hours, cost.Hourly, cost.Cost, cost.ProjectedMonthly)
+	}
+	fmt.Fprintf(tw, "TOTAL %s\t\t\t\t\t\t\t\t%.2f\t%.2f\n", r.Currency, r.Total, r.ProjectedMonthly)
+
+	if len(r.Unbilled) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "UNBILLED\tREASON")
+		for _, node := range r.Unbilled {
+			fmt.Fprintf(tw, "%s\t%s\n", node.NodeId, node.Reason)
+		}
+	}
+
+	return tw.Flush()
+}
+
+func (r CostReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"node_id", "region", "instance_type", "network", "deleted", "start", "end", "hours", "hourly", "cost", "projected_monthly"})
+	for _, cost := range r.Nodes {
+		cw.Write([]string{
+			cost.NodeId, cost.Region, cost.InstanceType, cost.Network,
+			strconv.FormatBool(cost.Deleted),
+			cost.Start.UTC().Format(time.RFC3339), cost.End.UTC().Format(time.RFC3339),
+			formatFloat(cost.Hours), formatFloat(cost.Hourly), formatFloat(cost.Cost), formatFloat(cost.ProjectedMonthly),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func WriteCostGroupsTable(w io.Writer, dimension CostDimension, groups []CostGroup) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tNODES\tHOURS\tCOST\tMONTHLY\n", dimensionHeader(dimension))
+	for _, group := range groups {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%.2f\t%.2f\n", group.Key, group.Nodes, group.Hours, group.Cost, group.ProjectedMonthly)
+	}
+	return tw.Flush()
+}
+
+func WriteCostGroupsCSV(w io.Writer, dimension CostDimension, groups []CostGroup) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{string(dimension), "nodes", "hours", "cost", "projected_monthly"})
+	for _, group := range groups {
+		cw.Write([]string{group.Key, strconv.Itoa(group.Nodes), formatFloat(group.Hours), formatFloat(group.Cost), formatFloat(group.ProjectedMonthly)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func dimensionHeader(dimension CostDimension) string {
+	return strings.ToUpper(strings.ReplaceAll(string(dimension), "_", " "))
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func fromMillis(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
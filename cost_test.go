@@ -0,0 +1,184 @@
+package marmotcoreclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func testPricing() *PricingTable {
+	return &PricingTable{
+		Currency: "USD",
+		Prices: []Price{
+			{InstanceType: "node.small", Hourly: 0.1},
+			{InstanceType: "node.small", Region: "eu-west-1", Hourly: 0.2},
+			{InstanceType: "node.large", Hourly: 1},
+		},
+	}
+}
+
+func TestLoadPricingTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pricing.yaml")
+	ioutil.WriteFile(path, []byte("currency: USD\nprices:\n  - instance_type: node.small\n    hourly: 0.1\n  - instance_type: node.small\n    region: eu-west-1\n    hourly: 0.2\n"), 0600)
+
+	table, err := LoadPricingTable(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "USD", table.Currency)
+
+	hourly, err := table.Hourly("us-west-2", "node.small")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.1, hourly)
+
+	hourly, _ = table.Hourly("eu-west-1", "node.small")
+	assert.Equal(t, 0.2, hourly)
+
+	_, err = table.Hourly("us-west-2", "node.huge")
+	assert.ErrorIs(t, err, ErrNoPrice)
+
+	jsonPath := filepath.Join(t.TempDir(), "pricing.json")
+	ioutil.WriteFile(jsonPath, []byte(`{"currency":"EUR","prices":[{"instance_type":"node.small","hourly":-1}]}`), 0600)
+	_, err = LoadPricingTable(jsonPath)
+	assert.Error(t, err)
+
+	_, err = LoadPricingTable(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestEstimateCosts(t *testing.T) {
+	start := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2022, 3, 11, 0, 0, 0, 0, time.UTC)
+
+	before := newNode("testUserId", millis(start.AddDate(0, -1, 0)), "before", "", "us-west-2", "node.small", "1.3.*", "mainnet", "R", false)
+	during := newNode("testUserId", millis(start.AddDate(0, 0, 5)), "during", "", "eu-west-1", "node.small", "1.3.*", "testnet", "R", false)
+	deleted := newNode("testUserId", millis(start.AddDate(0, 0, -1)), "deleted", "", "us-west-2", "node.large", "1.3.*", "mainnet", "D", true)
+	deleted.DeletedTime = int(millis(start.Add(12 * time.Hour)))
+	gone := newNode("testUserId", millis(start.AddDate(0, -2, 0)), "gone", "", "us-west-2", "node.large", "1.3.*", "mainnet", "D", true)
+	gone.DeletedTime = int(millis(start.AddDate(0, -1, 0)))
+	later := newNode("testUserId", millis(end.Add(time.Hour)), "later", "", "us-west-2", "node.small", "1.3.*", "mainnet", "R", false)
+	unpriced := newNode("testUserId", millis(start), "unpriced", "", "us-west-2", "node.huge", "1.3.*", "mainnet", "R", false)
+	noDeletedTime := newNode("testUserId", millis(start), "no-deleted-time", "", "us-west-2", "node.small", "1.3.*", "mainnet", "D", true)
+
+	report := testPricing().Estimate([]Node{*during, *before, *deleted, *gone, *later, *unpriced, *noDeletedTime}, start, end)
+
+	assert.Len(t, report.Nodes, 3)
+	assert.Equal(t, "before", report.Nodes[0].NodeId)
+	assert.Equal(t, start, report.Nodes[0].Start)
+	assert.Equal(t, 240.0, report.Nodes[0].Hours)
+	assert.InDelta(t, 24.0, report.Nodes[0].Cost, 1e-9)
+	assert.InDelta(t, 73.0, report.Nodes[0].ProjectedMonthly, 1e-9)
+
+	assert.Equal(t, "deleted", report.Nodes[1].NodeId)
+	assert.Equal(t, 12.0, report.Nodes[1].Hours)
+	assert.Equal(t, 12.0, report.Nodes[1].Cost)
+	assert.Zero(t, report.Nodes[1].ProjectedMonthly)
+
+	assert.Equal(t, "during", report.Nodes[2].NodeId)
+	assert.Equal(t, 0.2, report.Nodes[2].Hourly)
+	assert.InDelta(t, 24.0, report.Nodes[2].Cost, 1e-9)
+
+	assert.InDelta(t, 60.0, report.Total, 1e-9)
+	assert.InDelta(t, 219.0, report.ProjectedMonthly, 1e-9)
+
+	assert.Len(t, report.Unbilled, 2)
+	assert.Equal(t, "unpriced", report.Unbilled[0].NodeId)
+	assert.Equal(t, "no-deleted-time", report.Unbilled[1].NodeId)
+}
+
+func TestCostGroups(t *testing.T) {
+	start := time.Date(2022, 2, 27, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2022, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	a := newNode("testUserId", millis(start), "a", "", "us-west-2", "node.small", "1.3.*", "mainnet", "R", false)
+	b := newNode("testUserId", millis(start.Add(36*time.Hour)), "b", "", "eu-west-1", "node.small", "1.3.*", "testnet", "R", false)
+	report := testPricing().Estimate([]Node{*a, *b}, start, end)
+
+	regions := report.GroupBy(ByRegion)
+	assert.Len(t, regions, 2)
+	assert.Equal(t, "eu-west-1", regions[0].Key)
+	assert.Equal(t, 36.0, regions[0].Hours)
+	assert.Equal(t, "us-west-2", regions[1].Key)
+	assert.InDelta(t, 7.2, regions[1].Cost, 1e-9)
+
+	types := report.GroupBy(ByInstanceType)
+	assert.Len(t, types, 1)
+	assert.Equal(t, 2, types[0].Nodes)
+	assert.InDelta(t, report.ProjectedMonthly, types[0].ProjectedMonthly, 1e-9)
+
+	months := report.GroupBy(ByMonth)
+	assert.Len(t, months, 2)
+	assert.Equal(t, "2022-02", months[0].Key)
+	assert.Equal(t, 60.0, months[0].Hours)
+	assert.Equal(t, "2022-03", months[1].Key)
+	assert.Equal(t, 48.0, months[1].Hours)
+	assert.InDelta(t, report.Total, months[0].Cost+months[1].Cost, 1e-9)
+
+	days := report.GroupBy(ByDay)
+	assert.Len(t, days, 3)
+	assert.Equal(t, "2022-02-28", days[1].Key)
+	assert.Equal(t, 2, days[1].Nodes)
+	assert.Equal(t, 36.0, days[1].Hours)
+
+	_, err := ParseCostDimension("colour")
+	assert.Error(t, err)
+	dimension, _ := ParseCostDimension("network")
+	assert.Equal(t, ByNetwork, dimension)
+}
+
+func TestCostReportOutput(t *testing.T) {
+	start := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	node := newNode("testUserId", millis(start), "a", "", "us-west-2", "node.small", "1.3.*", "mainnet", "D", true)
+	node.DeletedTime = int(millis(start.Add(10 * time.Hour)))
+	report := testPricing().Estimate([]Node{*node}, start, start.AddDate(0, 0, 1))
+
+	var out bytes.Buffer
+	assert.NoError(t, report.WriteCSV(&out))
+	assert.Equal(t, "node_id,region,instance_type,network,deleted,start,end,hours,hourly,cost,projected_monthly\n"+
+		"a,us-west-2,node.small,mainnet,true,2022-03-01T00:00:00Z,2022-03-01T10:00:00Z,10,0.1,1,0\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, report.WriteTable(&out))
+	assert.Contains(t, out.String(), "(deleted)")
+	assert.Contains(t, out.String(), "TOTAL USD")
+
+	out.Reset()
+	assert.NoError(t, WriteCostGroupsTable(&out, ByInstanceType, report.GroupBy(ByInstanceType)))
+	assert.True(t, strings.HasPrefix(out.String(), "INSTANCE TYPE"))
+
+	out.Reset()
+	assert.NoError(t, WriteCostGroupsCSV(&out, ByRegion, report.GroupBy(ByRegion)))
+	assert.Equal(t, "region,nodes,hours,cost,projected_monthly\nus-west-2,1,10,1,0\n", out.String())
+
+	data, err := json.Marshal(report)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"deleted":true`)
+}
+
+func TestClientCosts(t *testing.T) {
+	created := time.Now().Add(-2 * time.Hour)
+	node := *newNode("testUserId", millis(created), "a", "", "us-west-2", "node.small", "1.3.*", "mainnet", "R", false)
+	mockNodesAndKeys(t, []Node{node}, nil)
+
+	mc := MarmotcoreClient{Protocol: "http", Host: "localhost", Port: "3000", ApiVersion: "v1"}
+	report, err := mc.Costs(testPricing(), created.Add(-time.Hour), time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, report.Nodes, 1)
+	assert.InDelta(t, 0.2, report.Total, 0.01)
+
+	GetFunc = func(url string) (*http.Response, error) {
+		return jsonResponse(500, `{"error":"internal"}`, nil), nil
+	}
+	_, err = mc.Costs(testPricing(), created.Add(-time.Hour), time.Now())
+	assert.EqualError(t, err, "listing nodes: status 500")
+}
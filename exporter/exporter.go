@@ -0,0 +1,426 @@
+// Package exporter serves MarmotCore fleet and Chia chain metrics in the
+// Prometheus text format.
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	marmotcoreclient "github.com/freddiecoleman/marmotcore-client"
+)
+
+const DefaultMaxNodes = 100
+
+// DefaultGroupBy are the node labels fleet counts are broken down by.
+var DefaultGroupBy = []string{"region", "state", "instance_type"}
+
+// Exporter collects metrics every Interval and serves the last collection.
+// Fleet counts come from ListNodes, grouped by the GroupBy labels out of
+// region, state, instance_type, network and chia_version. Per-node series,
+// node age and, with Chain set, chain metrics over RPC, are kept to the
+// first MaxNodes nodes by node ID to bound cardinality; a negative
+// MaxNodes turns them off.
+type Exporter struct {
+	Client   marmotcoreclient.MarmotcoreClient
+	RPC      marmotcoreclient.RPCOptions
+	Network  string
+	Interval time.Duration
+	GroupBy  []string
+	MaxNodes int
+	Chain    bool
+
+	// Extra, if set, is served after the exporter's own metrics, e.g. the
+	// client's *marmotcoreclient.Metrics.
+	Extra io.WriterTo
+
+	mu        sync.Mutex
+	text      string
+	collected bool
+
+	clientsMu sync.Mutex
+	clients   map[string]cachedClient
+}
+
+// cachedClient is a node's RPC client, kept between collections until the
+// node's IP or certificate changes.
+type cachedClient struct {
+	rpc      *marmotcoreclient.RPCClient
+	publicIp string
+	cert     string
+}
+
+type sample struct {
+	labels string
+	value  float64
+}
+
+type metric struct {
+	name    string
+	help    string
+	samples []sample
+}
+
+func (m *metric) add(value float64, labels ...string) {
+	var pairs []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+	}
+	m.samples = append(m.samples, sample{labels: strings.Join(pairs, ","), value: value})
+}
+
+func writeGauges(b *strings.Builder, metrics []*metric) {
+	for _, m := range metrics {
+		fmt.Fprintf(b, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(b, "# TYPE %s gauge\n", m.name)
+		for _, s := range m.samples {
+			value := strconv.FormatFloat(s.value, 'g', -1, 64)
+			if s.labels == "" {
+				fmt.Fprintf(b, "%s %s\n", m.name, value)
+			} else {
+				fmt.Fprintf(b, "%s{%s} %s\n", m.name, s.labels, value)
+			}
+		}
+	}
+}
+
+func nodeLabel(node marmotcoreclient.Node, name string) string {
+	switch name {
+	case "region":
+		return node.Region
+	case "state":
+		return node.State
+	case "instance_type":
+		return node.InstanceType
+	case "network":
+		return node.Network
+	case "chia_version":
+		return node.ChiaVersion
+	}
+	return ""
+}
+
+// sameNetwork compares network names, treating aliases like testnet as
+// the network they stand for.
+func sameNetwork(a string, b string) bool {
+	if network, err := marmotcoreclient.LookupNetwork(a); err == nil {
+		a = network.Name
+	}
+	if network, err := marmotcoreclient.LookupNetwork(b); err == nil {
+		b = network.Name
+	}
+	return a == b
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func bool01(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type chainStats struct {
+	up      bool
+	state   marmotcoreclient.BlockchainState
+	peers   int
+	scraped bool
+}
+
+// groupByLabels are the node labels nodeLabel knows.
+var groupByLabels = []string{"region", "state", "instance_type", "network", "chia_version"}
+
+// Validate checks that GroupBy holds distinct labels out of groupByLabels.
+func (e *Exporter) Validate() error {
+	var seen []string
+	for _, name := range e.GroupBy {
+		if !contains(groupByLabels, name) {
+			return fmt.Errorf("exporter: unknown group-by label %q, use %s", name, strings.Join(groupByLabels, ", "))
+		}
+		if contains(seen, name) {
+			return fmt.Errorf("exporter: group-by label %q repeated", name)
+		}
+		seen = append(seen, name)
+	}
+	return nil
+}
+
+// Collect gathers a fresh set of metrics. The previous set keeps being
+// served if listing the nodes fails.
+func (e *Exporter) Collect(ctx context.Context) error {
+	start := time.Now()
+
+	if err := e.Validate(); err != nil {
+		return err
+	}
+
+	nodes, err := e.Client.ListNodes()
+	if err != nil {
+		return err
+	}
+
+	groupBy := e.GroupBy
+	if groupBy == nil {
+		groupBy = DefaultGroupBy
+	}
+	maxNodes := e.MaxNodes
+	if maxNodes == 0 {
+		maxNodes = DefaultMaxNodes
+	}
+
+	var live []marmotcoreclient.Node
+	for _, node := range nodes {
+		if node.Deleted {
+			continue
+		}
+		if e.Network != "" && !sameNetwork(node.Network, e.Network) {
+			continue
+		}
+		live = append(live, node)
+	}
+	sort.Slice(live, func(i, j int) bool { return live[i].NodeId < live[j].NodeId })
+
+	counts := map[string]int{}
+	countLabels := map[string][]string{}
+	for _, node := range live {
+		var labels []string
+		for _, name := range groupBy {
+			labels = append(labels, name, nodeLabel(node, name))
+		}
+		key := strings.Join(labels, "\x00")
+		counts[key]++
+		countLabels[key] = labels
+	}
+
+	nodeCount := &metric{name: "marmotcore_nodes", help: "Nodes that are not deleted, by " + strings.Join(groupBy, ", ") + "."}
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		nodeCount.add(float64(counts[key]), countLabels[key]...)
+	}
+
+	perNode := live
+	if maxNodes < 0 {
+		perNode = nil
+	} else if len(perNode) > maxNodes {
+		perNode = perNode[:maxNodes]
+	}
+
+	age := &metric{name: "marmotcore_node_age_seconds", help: "Time since the node was created."}
+	for _, node := range perNode {
+		created := time.Unix(0, node.CreatedTime*int64(time.Millisecond))
+		age.add(start.Sub(created).Seconds(), "node_id", node.NodeId)
+	}
+
+	metrics := []*metric{nodeCount, age}
+	if e.Chain {
+		metrics = append(metrics, e.collectChain(ctx, perNode)...)
+	}
+
+	metrics = append(metrics,
+		&metric{name: "marmotcore_exporter_nodes_dropped", help: "Nodes left out of per-node series by the MaxNodes limit.",
+			samples: []sample{{value: float64(len(live) - len(perNode))}}},
+		&metric{name: "marmotcore_exporter_scrape_duration_seconds", help: "Time the last collection took.",
+			samples: []sample{{value: time.Since(start).Seconds()}}},
+		&metric{name: "marmotcore_exporter_last_scrape_timestamp_seconds", help: "Unix time of the last collection.",
+			samples: []sample{{value: float64(start.Unix())}}},
+	)
+
+	var b strings.Builder
+	writeGauges(&b, metrics)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.text = b.String()
+	e.collected = true
+
+	return nil
+}
+
+// chainClient returns the node's cached RPC client, building a new one
+// when there is none or the node's IP or cert has changed. An empty cert
+// means it isn't known, and only the IP is compared.
+func (e *Exporter) chainClient(node marmotcoreclient.Node, cert string) (*marmotcoreclient.RPCClient, error) {
+	e.clientsMu.Lock()
+	cached, ok := e.clients[node.NodeId]
+	e.clientsMu.Unlock()
+	if ok && cached.publicIp == node.PublicIp && (cert == "" || cached.cert == cert) {
+		return cached.rpc, nil
+	}
+
+	rpc, err := e.Client.RPCClientForNode(node, e.RPC)
+	if err != nil {
+		return nil, err
+	}
+
+	e.clientsMu.Lock()
+	defer e.clientsMu.Unlock()
+	if ok {
+		cached.rpc.Close()
+	}
+	if e.clients == nil {
+		e.clients = map[string]cachedClient{}
+	}
+	e.clients[node.NodeId] = cachedClient{rpc: rpc, publicIp: node.PublicIp, cert: cert}
+
+	return rpc, nil
+}
+
+// forgetClients closes and drops the cached clients of nodes that are no
+// longer scraped.
+func (e *Exporter) forgetClients(scraped map[string]bool) {
+	e.clientsMu.Lock()
+	defer e.clientsMu.Unlock()
+
+	for nodeId, cached := range e.clients {
+		if !scraped[nodeId] {
+			cached.rpc.Close()
+			delete(e.clients, nodeId)
+		}
+	}
+}
+
+func (e *Exporter) collectChain(ctx context.Context, nodes []marmotcoreclient.Node) []*metric {
+	stats := make([]chainStats, len(nodes))
+
+	// Listing the keys once tells which nodes had their key replaced
+	// without fetching every key. If it fails, cached clients are kept.
+	certs := map[string]string{}
+	if keys, err := e.Client.GetKeys(); err == nil {
+		for _, key := range keys.Keys {
+			certs[key.NodeId] = key.Cert
+		}
+	}
+	scraped := map[string]bool{}
+
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		if node.State != "R" || node.PublicIp == "" {
+			continue
+		}
+		stats[i].scraped = true
+		scraped[node.NodeId] = true
+
+		wg.Add(1)
+		go func(i int, node marmotcoreclient.Node, cert string) {
+			defer wg.Done()
+
+			rpc, err := e.chainClient(node, cert)
+			if err != nil {
+				return
+			}
+			state, err := rpc.GetBlockchainState(ctx)
+			if err != nil {
+				return
+			}
+			stats[i].up = true
+			stats[i].state = state
+
+			if connections, err := rpc.GetConnections(ctx); err == nil {
+				for _, connection := range connections {
+					if connection.Type == marmotcoreclient.NodeTypeFullNode {
+						stats[i].peers++
+					}
+				}
+			}
+		}(i, node, certs[node.NodeId])
+	}
+	wg.Wait()
+	e.forgetClients(scraped)
+
+	up := &metric{name: "chia_rpc_up", help: "Whether the node's full node RPC answered."}
+	peak := &metric{name: "chia_peak_height", help: "Height of the node's peak block."}
+	synced := &metric{name: "chia_synced", help: "Whether the node reports itself synced."}
+	syncProgress := &metric{name: "chia_sync_progress_percent", help: "How far the node has synced."}
+	peers := &metric{name: "chia_full_node_peers", help: "Full node peers the node is connected to."}
+	mempool := &metric{name: "chia_mempool_size", help: "Transactions in the node's mempool."}
+
+	for i, node := range nodes {
+		if !stats[i].scraped {
+			continue
+		}
+		up.add(bool01(stats[i].up), "node_id", node.NodeId)
+		if !stats[i].up {
+			continue
+		}
+		state := stats[i].state
+		peak.add(float64(state.PeakHeight()), "node_id", node.NodeId)
+		synced.add(bool01(state.Sync.Synced), "node_id", node.NodeId)
+		syncProgress.add(state.SyncProgress(), "node_id", node.NodeId)
+		peers.add(float64(stats[i].peers), "node_id", node.NodeId)
+		mempool.add(float64(state.MempoolSize), "node_id", node.NodeId)
+	}
+
+	return []*metric{up, peak, synced, syncProgress, peers, mempool}
+}
+
+// Run collects every Interval until ctx is done. Collection errors are
+// passed to onError, if set, and otherwise ignored.
+func (e *Exporter) Run(ctx context.Context, onError func(error)) error {
+	interval := e.Interval
+	if interval == 0 {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.Collect(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Exporter) WriteTo(w io.Writer) (int64, error) {
+	e.mu.Lock()
+	text := e.text
+	e.mu.Unlock()
+
+	n, err := io.WriteString(w, text)
+	return int64(n), err
+}
+
+// ServeHTTP serves the last collection, collecting first if nothing has
+// been collected yet.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	collected := e.collected
+	e.mu.Unlock()
+
+	if !collected {
+		if err := e.Collect(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	e.WriteTo(w)
+	if e.Extra != nil {
+		e.Extra.WriteTo(w)
+	}
+}
@@ -0,0 +1,152 @@
+package exporter
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	marmotcoreclient "github.com/freddiecoleman/marmotcore-client"
+	"github.com/freddiecoleman/marmotcore-client/chiatest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExporter(t *testing.T) {
+	node, err := chiatest.NewNode("chia-node", "testnet")
+	assert.NoError(t, err)
+	defer node.Close()
+	node.Chain.Generate(10)
+	node.Connect(marmotcoreclient.Connection{Type: marmotcoreclient.NodeTypeFullNode})
+
+	api := chiatest.NewAPI(node)
+	defer api.Close()
+	api.Update("chia-node", func(n *marmotcoreclient.Node) {
+		n.CreatedTime = time.Now().Add(-time.Hour).UnixNano() / int64(time.Millisecond)
+	})
+	api.AddRecords(
+		marmotcoreclient.Node{NodeId: "pending", Region: "us-west-2", State: "P", InstanceType: "node.small", Network: "testnet11"},
+		marmotcoreclient.Node{NodeId: "deleted", Region: "us-west-2", State: "T", Deleted: true, Network: "testnet"},
+		marmotcoreclient.Node{NodeId: "mainnet", Region: "us-west-2", State: "R", Network: "mainnet"},
+	)
+
+	exporter := &Exporter{
+		Client:  api.Client(),
+		RPC:     node.RPCOptions(),
+		Network: "testnet",
+		Chain:   true,
+	}
+
+	server := httptest.NewServer(exporter)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	text := string(body)
+
+	assert.Contains(t, text, "# TYPE marmotcore_nodes gauge\n")
+	assert.Contains(t, text, `marmotcore_nodes{region="local",state="R",instance_type="node.small"} 1`)
+	assert.Contains(t, text, `marmotcore_nodes{region="us-west-2",state="P",instance_type="node.small"} 1`)
+	assert.NotContains(t, text, `state="T"`)
+	assert.NotContains(t, text, `mainnet`)
+	assert.Contains(t, text, `marmotcore_node_age_seconds{node_id="chia-node"} 3600`)
+	assert.Contains(t, text, `chia_rpc_up{node_id="chia-node"} 1`)
+	assert.Contains(t, text, `chia_peak_height{node_id="chia-node"} 9`)
+	assert.Contains(t, text, `chia_synced{node_id="chia-node"} 1`)
+	assert.Contains(t, text, `chia_full_node_peers{node_id="chia-node"} 1`)
+	assert.Contains(t, text, `chia_mempool_size{node_id="chia-node"} 0`)
+	assert.NotContains(t, text, `chia_rpc_up{node_id="pending"}`)
+
+	exporter.GroupBy = []string{"network"}
+	exporter.MaxNodes = 1
+	assert.NoError(t, exporter.Collect(context.Background()))
+
+	var b strings.Builder
+	exporter.WriteTo(&b)
+	assert.Contains(t, b.String(), `marmotcore_nodes{network="testnet"} 1`)
+	assert.Contains(t, b.String(), `marmotcore_nodes{network="testnet11"} 1`)
+	assert.Contains(t, b.String(), "marmotcore_exporter_nodes_dropped 1\n")
+	assert.NotContains(t, b.String(), `node_id="pending"`)
+}
+
+func TestExporterRejectsInvalidGroupBy(t *testing.T) {
+	for _, groupBy := range [][]string{{"region", "bad-label"}, {"1region"}, {"region", "region"}, {"node_id"}} {
+		exporter := &Exporter{GroupBy: groupBy}
+		assert.Error(t, exporter.Validate(), groupBy)
+		assert.Error(t, exporter.Collect(context.Background()), groupBy)
+	}
+	assert.NoError(t, (&Exporter{GroupBy: []string{"network", "chia_version"}}).Validate())
+	assert.EqualError(t, (&Exporter{GroupBy: []string{"zone"}}).Validate(),
+		`exporter: unknown group-by label "zone", use region, state, instance_type, network, chia_version`)
+}
+
+func TestExporterServesOnlyTheErrorWhenCollectionFails(t *testing.T) {
+	api := httptest.NewServer(http.NotFoundHandler())
+	api.Close()
+
+	exporter := &Exporter{
+		Client: marmotcoreclient.MarmotcoreClient{Protocol: "http", Host: "127.0.0.1", Port: api.URL[strings.LastIndex(api.URL, ":")+1:], ApiVersion: "v1", HTTPClient: http.DefaultClient},
+		Extra:  strings.NewReader("extra_metric 1\n"),
+	}
+
+	recorder := httptest.NewRecorder()
+	exporter.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, http.StatusBadGateway, recorder.Code)
+	assert.NotContains(t, recorder.Body.String(), "extra_metric")
+}
+
+func TestExporterKeepsChainClients(t *testing.T) {
+	node, err := chiatest.NewNode("chia-node", "testnet")
+	assert.NoError(t, err)
+	defer node.Close()
+
+	api := chiatest.NewAPI(node)
+	defer api.Close()
+
+	exporter := &Exporter{Client: api.Client(), RPC: node.RPCOptions(), Chain: true}
+
+	assert.NoError(t, exporter.Collect(context.Background()))
+	first := exporter.clients["chia-node"].rpc
+	assert.NotNil(t, first)
+
+	assert.NoError(t, exporter.Collect(context.Background()))
+	assert.Same(t, first, exporter.clients["chia-node"].rpc)
+
+	api.Update("chia-node", func(n *marmotcoreclient.Node) { n.PublicIp = "127.0.0.2" })
+	assert.NoError(t, exporter.Collect(context.Background()))
+	assert.NotSame(t, first, exporter.clients["chia-node"].rpc)
+
+	api.Update("chia-node", func(n *marmotcoreclient.Node) { n.State = "S" })
+	assert.NoError(t, exporter.Collect(context.Background()))
+	assert.Empty(t, exporter.clients)
+}
+
+func TestExporterKeepsMetricsWhenTheAPIFails(t *testing.T) {
+	failing := false
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":"internal"}`))
+			return
+		}
+		w.Write([]byte(`{"nodes":[{"node_id":"a","region":"us-west-2","state":"R","instance_type":"node.small"}]}`))
+	}))
+	defer api.Close()
+
+	exporter := &Exporter{
+		Client: marmotcoreclient.MarmotcoreClient{Protocol: "http", Host: "127.0.0.1", Port: api.URL[strings.LastIndex(api.URL, ":")+1:], ApiVersion: "v1", HTTPClient: http.DefaultClient},
+	}
+	assert.NoError(t, exporter.Collect(context.Background()))
+
+	failing = true
+	assert.EqualError(t, exporter.Collect(context.Background()), "listing nodes: status 500")
+
+	var b strings.Builder
+	exporter.WriteTo(&b)
+	assert.Contains(t, b.String(), `marmotcore_nodes{region="us-west-2",state="R",instance_type="node.small"} 1`)
+}
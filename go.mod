@@ -3,8 +3,10 @@ module github.com/freddiecoleman/marmotcore-client
 go 1.18
 
 require (
-	github.com/davecgh/go-spew v1.1.0 // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/stretchr/testify v1.7.1 // indirect
-	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+	github.com/davecgh/go-spew v1.1.0
+	github.com/stretchr/testify v1.7.1
+	golang.org/x/crypto v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require github.com/pmezard/go-difflib v1.0.0 // indirect
@@ -0,0 +1,215 @@
+package marmotcoreclient
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var ErrOverrideRejected = errors.New("policy override token rejected")
+
+// Violation is one limit a request would break. Value is what the fleet
+// would reach if the request went ahead.
+type Violation struct {
+	Rule  string  `json:"rule"`
+	Scope string  `json:"scope,omitempty"`
+	Limit float64 `json:"limit"`
+	Value float64 `json:"value"`
+}
+
+func (v Violation) String() string {
+	rule := v.Rule
+	if v.Scope != "" {
+		rule += " " + v.Scope
+	}
+	return fmt.Sprintf("%s (%g > %g)", rule, v.Value, v.Limit)
+}
+
+// PolicyViolation is returned instead of sending a request that breaks a
+// policy. Use WithOverride to send it anyway.
+type PolicyViolation struct {
+	Operation  string
+	Violations []Violation
+}
+
+func (e *PolicyViolation) Error() string {
+	reasons := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		reasons[i] = violation.String()
+	}
+	return fmt.Sprintf("%s refused by policy: %s", e.Operation, strings.Join(reasons, "; "))
+}
+
+// Guardrails limit how far CreateNode can grow the fleet, counting every
+// node GetNodes reports as not deleted. Zero limits are not enforced.
+// MaxMonthlySpend needs Pricing, and every node has to have a price.
+// OverrideToken is a reference like Profile.Credentials, "env:NAME" or
+// "file:PATH", to the token that lets WithOverride bypass the limits.
+type Guardrails struct {
+	MaxNodes                int            `yaml:"max_nodes"`
+	MaxNodesPerRegion       map[string]int `yaml:"max_nodes_per_region"`
+	MaxNodesPerInstanceType map[string]int `yaml:"max_nodes_per_instance_type"`
+	MaxMonthlySpend         float64        `yaml:"max_monthly_spend"`
+	Pricing                 *PricingTable  `yaml:"pricing"`
+	OverrideToken           string         `yaml:"override_token"`
+}
+
+// CheckCreate returns a *PolicyViolation if adding createNode to nodes
+// would break a limit.
+func (g *Guardrails) CheckCreate(nodes []Node, createNode CreateNode) error {
+	total := 1
+	regions := map[string]int{createNode.Region: 1}
+	instanceTypes := map[string]int{createNode.InstanceType: 1}
+	var spend float64
+
+	if g.MaxMonthlySpend > 0 {
+		if g.Pricing == nil {
+			return errors.New("guardrails: max_monthly_spend needs a pricing table")
+		}
+		hourly, err := g.Pricing.Hourly(createNode.Region, createNode.InstanceType)
+		if err != nil {
+			return fmt.Errorf("guardrails: %w", err)
+		}
+		spend = hourly * HoursPerMonth
+	}
+
+	for _, node := range nodes {
+		if node.Deleted {
+			continue
+		}
+		total++
+		regions[node.Region]++
+		instanceTypes[node.InstanceType]++
+
+		if g.MaxMonthlySpend > 0 {
+			hourly, err := g.Pricing.Hourly(node.Region, node.InstanceType)
+			if err != nil {
+				return fmt.Errorf("guardrails: node %s: %w", node.NodeId, err)
+			}
+			spend += hourly * HoursPerMonth
+		}
+	}
+
+	var violations []Violation
+	if g.MaxNodes > 0 && total > g.MaxNodes {
+		violations = append(violations, Violation{Rule: "max_nodes", Limit: float64(g.MaxNodes), Value: float64(total)})
+	}
+	if limit, ok := g.MaxNodesPerRegion[createNode.Region]; ok && regions[createNode.Region] > limit {
+		violations = append(violations, Violation{Rule: "max_nodes_per_region", Scope: createNode.Region, Limit: float64(limit), Value: float64(regions[createNode.Region])})
+	}
+	if limit, ok := g.MaxNodesPerInstanceType[createNode.InstanceType]; ok && instanceTypes[createNode.InstanceType] > limit {
+		violations = append(violations, Violation{Rule: "max_nodes_per_instance_type", Scope: createNode.InstanceType, Limit: float64(limit), Value: float64(instanceTypes[createNode.InstanceType])})
+	}
+	if g.MaxMonthlySpend > 0 && spend > g.MaxMonthlySpend {
+		violations = append(violations, Violation{Rule: "max_monthly_spend", Limit: g.MaxMonthlySpend, Value: spend})
+	}
+
+	if len(violations) > 0 {
+		return &PolicyViolation{Operation: "CreateNode", Violations: violations}
+	}
+	return nil
+}
+
+// allowOverride reports whether token matches the configured override
+// token. Without a configured token nothing can be overridden.
+func (g *Guardrails) allowOverride(token string) (bool, error) {
+	if g.OverrideToken == "" || token == "" {
+		return false, nil
+	}
+
+	expected, err := resolveReference(g.OverrideToken)
+	if err != nil {
+		return false, fmt.Errorf("guardrails override token: %w", err)
+	}
+
+	return expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1, nil
+}
+
+// WithOverride returns a copy of the client that sends requests refused by
+// its Guardrails anyway, provided token matches their override token.
+func (mc MarmotcoreClient) WithOverride(token string) MarmotcoreClient {
+	mc.overrideToken = token
+	return mc
+}
+
+// overrideRejectedError keeps the violation reachable with errors.As while
+// matching ErrOverrideRejected with errors.Is.
+type overrideRejectedError struct {
+	violation *PolicyViolation
+}
+
+func (e *overrideRejectedError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrOverrideRejected, e.violation)
+}
+
+func (e *overrideRejectedError) Is(target error) bool {
+	return target == ErrOverrideRejected
+}
+
+func (e *overrideRejectedError) Unwrap() error {
+	return e.violation
+}
+
+// ListNodes lists every node like GetNodes, but fails when the API doesn't
+// answer 200 with a node list, for callers that must not mistake an
+// unknown fleet for an empty one.
+func (mc MarmotcoreClient) ListNodes() ([]Node, error) {
+	return mc.fleetNodes()
+}
+
+// fleetNodes lists every node for counting against limits. Unlike GetNodes
+// it fails on an error status or a body it can't read, so an unknown fleet
+// is never counted as an empty one.
+func (mc MarmotcoreClient) fleetNodes() ([]Node, error) {
+	var statusCode int
+	body, err := mc.call("GetNodes", "", 0, func() (*http.Response, error) {
+		resp, err := mc.getRequest("/nodes")
+		if resp != nil {
+			statusCode = resp.StatusCode
+		}
+		return resp, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing nodes: status %d", statusCode)
+	}
+
+	var nodes NodesResponse
+	if err := json.Unmarshal(body, &nodes); err != nil {
+		return nil, fmt.Errorf("listing nodes: %w", err)
+	}
+
+	return nodes.Nodes, nil
+}
+
+func (mc MarmotcoreClient) checkCreate(createNode *CreateNode) error {
+	if mc.Guardrails == nil {
+		return nil
+	}
+
+	nodes, err := mc.fleetNodes()
+	if err != nil {
+		return fmt.Errorf("guardrails: refusing to create a node without a node count: %w", err)
+	}
+
+	err = mc.Guardrails.CheckCreate(nodes, *createNode)
+	var violation *PolicyViolation
+	if !errors.As(err, &violation) || mc.overrideToken == "" {
+		return err
+	}
+
+	allowed, err := mc.Guardrails.allowOverride(mc.overrideToken)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return &overrideRejectedError{violation: violation}
+	}
+
+	return nil
+}
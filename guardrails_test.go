@@ -0,0 +1,135 @@
+package marmotcoreclient
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func testFleet() []Node {
+	return []Node{
+		*newNode("testUserId", 1648394251715, "a", "", "us-west-2", "node.small", "1.3.*", "mainnet", "R", false),
+		*newNode("testUserId", 1648394251715, "b", "", "us-west-2", "node.large", "1.3.*", "mainnet", "R", false),
+		*newNode("testUserId", 1648394251715, "c", "", "eu-west-1", "node.small", "1.3.*", "testnet", "P", false),
+		*newNode("testUserId", 1648394251715, "d", "", "us-west-2", "node.large", "1.3.*", "mainnet", "D", true),
+	}
+}
+
+func TestGuardrailsCheckCreate(t *testing.T) {
+	guardrails := &Guardrails{
+		MaxNodes:                4,
+		MaxNodesPerRegion:       map[string]int{"us-west-2": 2},
+		MaxNodesPerInstanceType: map[string]int{"node.large": 5},
+		MaxMonthlySpend:         1500,
+		Pricing:                 testPricing(),
+	}
+
+	assert.NoError(t, guardrails.CheckCreate(testFleet(), CreateNode{Region: "eu-west-1", InstanceType: "node.small"}))
+
+	err := guardrails.CheckCreate(testFleet(), CreateNode{Region: "us-west-2", InstanceType: "node.large"})
+	var violation *PolicyViolation
+	assert.True(t, errors.As(err, &violation))
+	assert.Equal(t, "CreateNode", violation.Operation)
+	assert.Equal(t, []Violation{
+		{Rule: "max_nodes_per_region", Scope: "us-west-2", Limit: 2, Value: 3},
+		{Rule: "max_monthly_spend", Limit: 1500, Value: 1679},
+	}, violation.Violations)
+	assert.Equal(t, "CreateNode refused by policy: max_nodes_per_region us-west-2 (3 > 2); max_monthly_spend (1679 > 1500)", err.Error())
+
+	fleet := append(testFleet(), *newNode("testUserId", 1648394251715, "e", "", "ap-south-1", "node.small", "1.3.*", "mainnet", "R", false))
+	err = guardrails.CheckCreate(fleet, CreateNode{Region: "eu-west-1", InstanceType: "node.small"})
+	assert.True(t, errors.As(err, &violation))
+	assert.Equal(t, "max_nodes", violation.Violations[0].Rule)
+
+	err = guardrails.CheckCreate(testFleet(), CreateNode{Region: "eu-west-1", InstanceType: "node.huge"})
+	assert.ErrorIs(t, err, ErrNoPrice)
+	assert.False(t, errors.As(err, &violation))
+
+	assert.Error(t, (&Guardrails{MaxMonthlySpend: 10}).CheckCreate(nil, CreateNode{}))
+	assert.NoError(t, (&Guardrails{}).CheckCreate(testFleet(), CreateNode{}))
+}
+
+func TestCreateNodeGuardrails(t *testing.T) {
+	t.Setenv("MARMOTCORE_OVERRIDE", "break-glass")
+	mockNodesAndKeys(t, testFleet(), nil)
+
+	posts := 0
+	PostFunc = func(url string, contentType string, body io.Reader) (*http.Response, error) {
+		posts++
+		return jsonResponse(200, `{"node_id":"new"}`, nil), nil
+	}
+
+	mc := MarmotcoreClient{Protocol: "http", Host: "localhost", Port: "3000", ApiVersion: "v1", Guardrails: &Guardrails{MaxNodes: 3}}
+	createNode := &CreateNode{Region: "us-west-2", InstanceType: "node.small"}
+
+	_, err := mc.CreateNode(createNode)
+	var violation *PolicyViolation
+	assert.True(t, errors.As(err, &violation))
+	assert.Equal(t, 0, posts)
+
+	_, err = mc.WithOverride("break-glass").CreateNode(createNode)
+	assert.ErrorIs(t, err, ErrOverrideRejected)
+	assert.True(t, errors.As(err, &violation))
+	assert.Equal(t, "max_nodes", violation.Violations[0].Rule)
+	assert.Equal(t, 0, posts)
+
+	mc.Guardrails.OverrideToken = "env:MARMOTCORE_OVERRIDE"
+	_, err = mc.WithOverride("guess").CreateNode(createNode)
+	assert.ErrorIs(t, err, ErrOverrideRejected)
+
+	response, err := mc.WithOverride("break-glass").CreateNode(createNode)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", response.NodeId)
+	assert.Equal(t, 1, posts)
+
+	mc.Guardrails.MaxNodes = 10
+	_, err = mc.CreateNode(createNode)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, posts)
+
+	for _, reply := range []*http.Response{jsonResponse(500, `{"error":"internal"}`, nil), jsonResponse(200, `<html>`, nil)} {
+		reply := reply
+		GetFunc = func(url string) (*http.Response, error) { return reply, nil }
+		_, err = mc.WithOverride("break-glass").CreateNode(createNode)
+		assert.Error(t, err)
+		assert.False(t, errors.As(err, &violation))
+	}
+	assert.Equal(t, 2, posts)
+}
+
+func TestProfileGuardrails(t *testing.T) {
+	var config Config
+	err := yaml.Unmarshal([]byte(`
+profiles:
+  prod:
+    endpoint: https://api.marmotcore.com/v1
+    guardrails:
+      max_nodes: 20
+      max_nodes_per_region:
+        us-west-2: 10
+      max_monthly_spend: 500
+      override_token: file:`+filepath.Join(t.TempDir(), "override")+`
+      pricing:
+        currency: USD
+        prices:
+          - instance_type: node.small
+            hourly: 0.05
+`), &config)
+	assert.NoError(t, err)
+
+	profile, err := config.Profile("prod")
+	assert.NoError(t, err)
+	mc, err := profile.Client()
+	assert.NoError(t, err)
+	assert.Equal(t, 20, mc.Guardrails.MaxNodes)
+	assert.Equal(t, 10, mc.Guardrails.MaxNodesPerRegion["us-west-2"])
+
+	hourly, err := mc.Guardrails.Pricing.Hourly("us-west-2", "node.small")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.05, hourly)
+}
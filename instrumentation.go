@@ -0,0 +1,273 @@
+package marmotcoreclient
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Call describes a single MarmotCore API call, e.g. marmotcore.CreateNode,
+// or node RPC call, e.g. chia.get_blockchain_state.
+type Call struct {
+	Operation string
+	NodeId    string
+	Start     time.Time
+}
+
+// CallResult is reported once a call has finished. StatusCode is zero when
+// the request never got a response. Cached is set when the response came
+// from the response cache without a request. The client does not retry
+// requests itself, so Retries is only non-zero for callers that report
+// their own.
+type CallResult struct {
+	StatusCode    int
+	Cached        bool
+	Err           error
+	Duration      time.Duration
+	Retries       int
+	BytesSent     int
+	BytesReceived int
+}
+
+// Failed reports whether the call errored or the API answered with a 4xx/5xx.
+func (r CallResult) Failed() bool {
+	return r.Err != nil || r.StatusCode >= 400
+}
+
+// Instrumenter is invoked around every API call. StartCall returns the
+// function that is called with the result when the call ends.
+type Instrumenter interface {
+	StartCall(call Call) func(result CallResult)
+}
+
+var Instrumentation Instrumenter
+
+func startCall(call Call) func(result CallResult) {
+	call.Start = time.Now()
+
+	instrumentation := Instrumentation
+	if instrumentation == nil {
+		return func(result CallResult) {}
+	}
+
+	end := instrumentation.StartCall(call)
+
+	return func(result CallResult) {
+		result.Duration = time.Since(call.Start)
+		end(result)
+	}
+}
+
+type multiInstrumenter []Instrumenter
+
+// MultiInstrumenter fans every call out to all of the given instrumenters.
+func MultiInstrumenter(instrumenters ...Instrumenter) Instrumenter {
+	return multiInstrumenter(instrumenters)
+}
+
+func (m multiInstrumenter) StartCall(call Call) func(result CallResult) {
+	ends := make([]func(CallResult), len(m))
+	for i, instrumenter := range m {
+		ends[i] = instrumenter.StartCall(call)
+	}
+
+	return func(result CallResult) {
+		for _, end := range ends {
+			end(result)
+		}
+	}
+}
+
+// Span is the subset of an OpenTelemetry span used by TracingInstrumenter,
+// so an otel trace.Span can be adapted without this package importing otel.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+type Tracer interface {
+	StartSpan(name string, start time.Time) Span
+}
+
+// TracingInstrumenter opens one span per call, named after the operation.
+type TracingInstrumenter struct {
+	Tracer Tracer
+}
+
+func (t TracingInstrumenter) StartCall(call Call) func(result CallResult) {
+	span := t.Tracer.StartSpan(call.Operation, call.Start)
+	span.SetAttribute("rpc.system", "marmotcore")
+	span.SetAttribute("rpc.method", strings.TrimPrefix(call.Operation, "marmotcore."))
+	if call.NodeId != "" {
+		span.SetAttribute("marmotcore.node_id", call.NodeId)
+	}
+
+	return func(result CallResult) {
+		if result.StatusCode != 0 {
+			span.SetAttribute("http.status_code", result.StatusCode)
+		}
+		span.SetAttribute("marmotcore.cache_hit", result.Cached)
+		span.SetAttribute("marmotcore.retries", result.Retries)
+		span.SetAttribute("http.request_content_length", result.BytesSent)
+		span.SetAttribute("http.response_content_length", result.BytesReceived)
+
+		if result.Err != nil {
+			span.RecordError(result.Err)
+		} else if result.StatusCode >= 400 {
+			span.RecordError(fmt.Errorf("%s returned status %d", call.Operation, result.StatusCode))
+		}
+
+		span.End()
+	}
+}
+
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Metrics is an Instrumenter that keeps Prometheus-style counters and a
+// latency histogram per operation. It serves them in the Prometheus text
+// exposition format, either through WriteTo or as an http.Handler.
+type Metrics struct {
+	buckets []float64
+
+	mu        sync.Mutex
+	requests  map[[2]string]uint64
+	errors    map[string]uint64
+	retries   map[string]uint64
+	bytesSent map[string]uint64
+	bytesRecv map[string]uint64
+	latency   map[string]*histogram
+}
+
+func NewMetrics(buckets []float64) *Metrics {
+	if buckets == nil {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Metrics{
+		buckets:   buckets,
+		requests:  map[[2]string]uint64{},
+		errors:    map[string]uint64{},
+		retries:   map[string]uint64{},
+		bytesSent: map[string]uint64{},
+		bytesRecv: map[string]uint64{},
+		latency:   map[string]*histogram{},
+	}
+}
+
+func (m *Metrics) StartCall(call Call) func(result CallResult) {
+	return func(result CallResult) {
+		m.observe(call.Operation, result)
+	}
+}
+
+func (m *Metrics) observe(operation string, result CallResult) {
+	status := "error"
+	if result.StatusCode != 0 {
+		status = strconv.Itoa(result.StatusCode)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[[2]string{operation, status}]++
+	if result.Failed() {
+		m.errors[operation]++
+	}
+	m.retries[operation] += uint64(result.Retries)
+	m.bytesSent[operation] += uint64(result.BytesSent)
+	m.bytesRecv[operation] += uint64(result.BytesReceived)
+
+	h, ok := m.latency[operation]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.latency[operation] = h
+	}
+	seconds := result.Duration.Seconds()
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+
+	m.mu.Lock()
+
+	b.WriteString("# HELP marmotcore_requests_total MarmotCore API (marmotcore.*) and node RPC (chia.*) calls by operation and status code.\n")
+	b.WriteString("# TYPE marmotcore_requests_total counter\n")
+	requestKeys := make([][2]string, 0, len(m.requests))
+	for key := range m.requests {
+		requestKeys = append(requestKeys, key)
+	}
+	sort.Slice(requestKeys, func(i, j int) bool {
+		if requestKeys[i][0] != requestKeys[j][0] {
+			return requestKeys[i][0] < requestKeys[j][0]
+		}
+		return requestKeys[i][1] < requestKeys[j][1]
+	})
+	for _, key := range requestKeys {
+		fmt.Fprintf(&b, "marmotcore_requests_total{operation=%q,status=%q} %d\n", key[0], key[1], m.requests[key])
+	}
+
+	writeCounter(&b, "marmotcore_request_errors_total", "MarmotCore API and node RPC calls that failed or returned 4xx/5xx.", m.errors)
+	writeCounter(&b, "marmotcore_request_retries_total", "Retries of MarmotCore API and node RPC calls.", m.retries)
+	writeCounter(&b, "marmotcore_request_sent_bytes_total", "Request body bytes sent to the MarmotCore API and nodes.", m.bytesSent)
+	writeCounter(&b, "marmotcore_request_received_bytes_total", "Response body bytes received from the MarmotCore API and nodes.", m.bytesRecv)
+
+	b.WriteString("# HELP marmotcore_request_duration_seconds Latency of MarmotCore API and node RPC calls.\n")
+	b.WriteString("# TYPE marmotcore_request_duration_seconds histogram\n")
+	for _, operation := range sortedKeys(m.latency) {
+		h := m.latency[operation]
+		for i, bound := range m.buckets {
+			fmt.Fprintf(&b, "marmotcore_request_duration_seconds_bucket{operation=%q,le=%q} %d\n", operation, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(&b, "marmotcore_request_duration_seconds_bucket{operation=%q,le=\"+Inf\"} %d\n", operation, h.count)
+		fmt.Fprintf(&b, "marmotcore_request_duration_seconds_sum{operation=%q} %s\n", operation, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "marmotcore_request_duration_seconds_count{operation=%q} %d\n", operation, h.count)
+	}
+
+	m.mu.Unlock()
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
+
+func writeCounter(b *strings.Builder, name string, help string, values map[string]uint64) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s counter\n", name)
+	for _, operation := range sortedKeys(values) {
+		fmt.Fprintf(b, "%s{operation=%q} %d\n", name, operation, values[operation])
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,108 @@
+package marmotcoreclient
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordedSpan struct {
+	name       string
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+func (s *recordedSpan) SetAttribute(key string, value interface{}) {
+	s.attributes[key] = value
+}
+
+func (s *recordedSpan) RecordError(err error) {
+	s.err = err
+}
+
+func (s *recordedSpan) End() {
+	s.ended = true
+}
+
+type recordingTracer struct {
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) StartSpan(name string, start time.Time) Span {
+	span := &recordedSpan{name: name, attributes: map[string]interface{}{}}
+	t.spans = append(t.spans, span)
+	return span
+}
+
+func TestTracingInstrumenter(t *testing.T) {
+	tracer := &recordingTracer{}
+	Instrumentation = TracingInstrumenter{Tracer: tracer}
+	defer func() { Instrumentation = nil }()
+
+	PostFunc = func(url string, contentType string, body io.Reader) (resp *http.Response, err error) {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"node_id":"chia-node"}`))),
+		}, nil
+	}
+	DoFunc = func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}
+	Client = &MockClient{}
+	mc := MarmotcoreClient{Protocol: "http", Host: "localhost", Port: "3000", ApiVersion: "v1"}
+
+	mc.CreateNode(&CreateNode{Region: "us-west-2", InstanceType: "node.small", ChiaVersion: "1.3.*", Network: "testnet"})
+	mc.DeleteNode("chia-node")
+
+	assert.Len(t, tracer.spans, 2)
+
+	create := tracer.spans[0]
+	assert.Equal(t, "marmotcore.CreateNode", create.name)
+	assert.Equal(t, 200, create.attributes["http.status_code"])
+	assert.Equal(t, 23, create.attributes["http.response_content_length"])
+	assert.NotContains(t, create.attributes, "marmotcore.node_id")
+	assert.Nil(t, create.err)
+	assert.True(t, create.ended)
+
+	del := tracer.spans[1]
+	assert.Equal(t, "marmotcore.DeleteNode", del.name)
+	assert.Equal(t, "chia-node", del.attributes["marmotcore.node_id"])
+	assert.NotContains(t, del.attributes, "http.status_code")
+	assert.EqualError(t, del.err, "connection refused")
+	assert.True(t, del.ended)
+}
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics([]float64{0.1, 1})
+
+	metrics.observe("marmotcore.GetNode", CallResult{StatusCode: 200, Duration: 50 * time.Millisecond, BytesReceived: 100})
+	metrics.observe("marmotcore.GetNode", CallResult{StatusCode: 404, Duration: 500 * time.Millisecond, BytesReceived: 20})
+	metrics.observe("marmotcore.DeleteNode", CallResult{Err: errors.New("timeout"), Duration: 2 * time.Second})
+
+	var out strings.Builder
+	metrics.WriteTo(&out)
+
+	for _, line := range []string{
+		`marmotcore_requests_total{operation="marmotcore.DeleteNode",status="error"} 1`,
+		`marmotcore_requests_total{operation="marmotcore.GetNode",status="200"} 1`,
+		`marmotcore_requests_total{operation="marmotcore.GetNode",status="404"} 1`,
+		`marmotcore_request_errors_total{operation="marmotcore.GetNode"} 1`,
+		`marmotcore_request_errors_total{operation="marmotcore.DeleteNode"} 1`,
+		`marmotcore_request_received_bytes_total{operation="marmotcore.GetNode"} 120`,
+		`marmotcore_request_duration_seconds_bucket{operation="marmotcore.GetNode",le="0.1"} 1`,
+		`marmotcore_request_duration_seconds_bucket{operation="marmotcore.GetNode",le="1"} 2`,
+		`marmotcore_request_duration_seconds_bucket{operation="marmotcore.GetNode",le="+Inf"} 2`,
+		`marmotcore_request_duration_seconds_bucket{operation="marmotcore.DeleteNode",le="1"} 0`,
+		`marmotcore_request_duration_seconds_count{operation="marmotcore.DeleteNode"} 1`,
+	} {
+		assert.Contains(t, out.String(), line+"\n")
+	}
+}
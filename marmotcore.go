@@ -15,6 +15,12 @@ type MarmotcoreClient struct {
 	Host       string
 	Port       string
 	ApiVersion string
+	HTTPClient HTTPClient
+	Guardrails *Guardrails
+	Policy     *Policy
+	Protection *DeletionProtection
+
+	overrideToken string
 }
 
 type HTTPClient interface {
@@ -33,12 +39,19 @@ func (mc MarmotcoreClient) url() string {
 	return mc.Protocol + "://" + mc.Host + ":" + mc.Port + "/" + mc.ApiVersion
 }
 
+func (mc MarmotcoreClient) httpClient() HTTPClient {
+	if mc.HTTPClient != nil {
+		return mc.HTTPClient
+	}
+	return Client
+}
+
 func (mc MarmotcoreClient) getRequest(path string) (resp *http.Response, err error) {
-	return Client.Get(mc.url() + path)
+	return mc.httpClient().Get(mc.url() + path)
 }
 
 func (mc MarmotcoreClient) postRequest(path string, body io.Reader) (resp *http.Response, err error) {
-	return Client.Post(mc.url()+path, "application/json", body)
+	return mc.httpClient().Post(mc.url()+path, "application/json", body)
 }
 
 func (mc MarmotcoreClient) deleteRequest(path string) (resp *http.Response, err error) {
@@ -47,7 +60,25 @@ func (mc MarmotcoreClient) deleteRequest(path string) (resp *http.Response, err
 		fmt.Printf("error %s", err)
 		return
 	}
-	return Client.Do(req)
+	return mc.httpClient().Do(req)
+}
+
+func (mc MarmotcoreClient) call(operation string, nodeId string, bytesSent int, send func() (*http.Response, error)) ([]byte, error) {
+	end := startCall(Call{Operation: "marmotcore." + operation, NodeId: nodeId})
+
+	resp, err := send()
+
+	if err != nil {
+		end(CallResult{BytesSent: bytesSent, Err: err})
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+
+	end(CallResult{StatusCode: resp.StatusCode, BytesSent: bytesSent, BytesReceived: len(body), Err: err})
+
+	return body, err
 }
 
 type Node struct {
@@ -65,10 +96,10 @@ type Node struct {
 }
 
 type CreateNode struct {
-	Region       string `json:"region"`
-	InstanceType string `json:"instance_type"`
-	ChiaVersion  string `json:"chia_version"`
-	Network      string `json:"network"`
+	Region       string `json:"region" yaml:"region"`
+	InstanceType string `json:"instance_type" yaml:"instance_type"`
+	ChiaVersion  string `json:"chia_version" yaml:"chia_version"`
+	Network      string `json:"network" yaml:"network"`
 }
 
 type NodesResponse struct {
@@ -90,16 +121,15 @@ type DeleteNodeResponse struct {
 func (mc MarmotcoreClient) GetNodes() (NodesResponse, error) {
 	var nodes NodesResponse
 
-	resp, err := mc.getRequest("/nodes")
+	body, err := mc.call("GetNodes", "", 0, func() (*http.Response, error) {
+		return mc.getRequest("/nodes")
+	})
 
 	if err != nil {
 		fmt.Printf("Error %s", err)
 		return nodes, err
 	}
 
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-
 	json.Unmarshal(body, &nodes)
 
 	return nodes, nil
@@ -108,18 +138,29 @@ func (mc MarmotcoreClient) GetNodes() (NodesResponse, error) {
 func (mc MarmotcoreClient) CreateNode(createNode *CreateNode) (CreateNodeResponse, error) {
 	var createNodeResponse CreateNodeResponse
 
+	if err := createNode.Validate(); err != nil {
+		return createNodeResponse, err
+	}
+
+	if err := mc.authorize(CreateNodeRequest(*createNode)); err != nil {
+		return createNodeResponse, err
+	}
+
+	if err := mc.checkCreate(createNode); err != nil {
+		return createNodeResponse, err
+	}
+
 	createNodeBytes, err := json.Marshal(createNode)
 
-	resp, err := mc.postRequest("/nodes", bytes.NewBuffer(createNodeBytes))
+	body, err := mc.call("CreateNode", "", len(createNodeBytes), func() (*http.Response, error) {
+		return mc.postRequest("/nodes", bytes.NewBuffer(createNodeBytes))
+	})
 
 	if err != nil {
 		fmt.Printf("Error %s", err)
 		return createNodeResponse, err
 	}
 
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-
 	json.Unmarshal(body, &createNodeResponse)
 
 	return createNodeResponse, nil
@@ -128,33 +169,41 @@ func (mc MarmotcoreClient) CreateNode(createNode *CreateNode) (CreateNodeRespons
 func (mc MarmotcoreClient) GetNode(nodeId string) (NodeResponse, error) {
 	var node NodeResponse
 
-	resp, err := mc.getRequest("/nodes/" + nodeId)
+	body, err := mc.cachedGet("GetNode", nodeId, "/nodes/"+nodeId)
 
 	if err != nil {
 		fmt.Printf("Error %s", err)
 		return node, err
 	}
 
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-
 	json.Unmarshal(body, &node)
 
 	return node, nil
 }
 
+// DeleteNode deletes a node unless the client's Policy denies it. With
+// DeletionProtection set, use DeleteNodeConfirmed instead.
 func (mc MarmotcoreClient) DeleteNode(nodeId string) (DeleteNodeResponse, error) {
+	if err := mc.checkDelete(nodeId, ""); err != nil {
+		return DeleteNodeResponse{}, err
+	}
+
+	return mc.deleteNode(nodeId)
+}
+
+func (mc MarmotcoreClient) deleteNode(nodeId string) (DeleteNodeResponse, error) {
 	var deleteNode DeleteNodeResponse
 
-	resp, err := mc.deleteRequest("/nodes/" + nodeId)
+	body, err := mc.call("DeleteNode", nodeId, 0, func() (*http.Response, error) {
+		return mc.deleteRequest("/nodes/" + nodeId)
+	})
 
 	if err != nil {
 		fmt.Printf("Error %s", err)
 		return deleteNode, err
 	}
 
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+	Cache.invalidateNode(mc.url(), nodeId)
 
 	json.Unmarshal(body, &deleteNode)
 
@@ -164,7 +213,7 @@ func (mc MarmotcoreClient) DeleteNode(nodeId string) (DeleteNodeResponse, error)
 type Key struct {
 	UserId string `json:"user_id"`
 	NodeId string `json:"node_id"`
-	Key    string `json:"key"`
+	Key    Secret `json:"key"`
 	Cert   string `json:"cert"`
 }
 
@@ -179,16 +228,13 @@ type KeysResponse struct {
 func (mc MarmotcoreClient) GetKey(nodeId string) (KeyResponse, error) {
 	var key KeyResponse
 
-	resp, err := mc.getRequest("/keys/" + nodeId)
+	body, err := mc.cachedGet("GetKey", nodeId, "/keys/"+nodeId)
 
 	if err != nil {
 		fmt.Printf("Error %s", err)
 		return key, err
 	}
 
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-
 	json.Unmarshal(body, &key)
 
 	return key, nil
@@ -197,16 +243,13 @@ func (mc MarmotcoreClient) GetKey(nodeId string) (KeyResponse, error) {
 func (mc MarmotcoreClient) GetKeys() (KeysResponse, error) {
 	var keys KeysResponse
 
-	resp, err := mc.getRequest("/keys")
+	body, err := mc.cachedGet("GetKeys", "", "/keys")
 
 	if err != nil {
 		fmt.Printf("Error %s", err)
 		return keys, err
 	}
 
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-
 	json.Unmarshal(body, &keys)
 
 	return keys, nil
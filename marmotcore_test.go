@@ -2,9 +2,11 @@ package marmotcoreclient
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -32,6 +34,38 @@ func (m *MockClient) Post(url string, contentType string, body io.Reader) (resp
 	return PostFunc(url, contentType, body)
 }
 
+func mockNodeAndKey(t *testing.T, node Node, key Key) {
+	mockNodesAndKeys(t, []Node{node}, []Key{key})
+}
+
+func mockNodesAndKeys(t *testing.T, nodes []Node, keys []Key) {
+	GetFunc = func(url string) (*http.Response, error) {
+		var body []byte
+		if strings.HasSuffix(url, "/nodes") {
+			body, _ = json.Marshal(NodesResponse{Nodes: nodes})
+			return jsonResponse(200, string(body), nil), nil
+		}
+		if strings.HasSuffix(url, "/keys") {
+			body, _ = json.Marshal(KeysResponse{Keys: keys})
+			return jsonResponse(200, string(body), nil), nil
+		}
+		for _, node := range nodes {
+			if strings.HasSuffix(url, "/nodes/"+node.NodeId) {
+				body, _ = json.Marshal(NodeResponse{Node: node})
+				return jsonResponse(200, string(body), nil), nil
+			}
+		}
+		for _, key := range keys {
+			if strings.HasSuffix(url, "/keys/"+key.NodeId) {
+				body, _ = json.Marshal(KeyResponse{Key: key})
+				return jsonResponse(200, string(body), nil), nil
+			}
+		}
+		return jsonResponse(404, `{}`, nil), nil
+	}
+	Client = &MockClient{}
+}
+
 func newNode(userId string, createdTime int64, nodeId string, publicIp string, region string, instanceType string, chiaVersion string, network string, state string, deleted bool) *Node {
 	return &Node{
 		UserId:       userId,
@@ -189,7 +223,7 @@ func newKey(userId string, nodeId string, key string, cert string) *Key {
 	return &Key{
 		UserId: userId,
 		NodeId: nodeId,
-		Key:    key,
+		Key:    NewSecret(key),
 		Cert:   cert,
 	}
 }
@@ -0,0 +1,271 @@
+package marmotcoreclient
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type MempoolItem struct {
+	SpendBundle          SpendBundle `json:"spend_bundle"`
+	SpendBundleName      Bytes32     `json:"spend_bundle_name"`
+	Fee                  uint64      `json:"fee"`
+	Cost                 uint64      `json:"cost"`
+	Additions            []Coin      `json:"additions"`
+	Removals             []Coin      `json:"removals"`
+	HeightAddedToMempool uint32      `json:"height_added_to_mempool"`
+}
+
+// FeeRate is the fee in mojos per unit of cost.
+func (i MempoolItem) FeeRate() float64 {
+	if i.Cost == 0 {
+		return 0
+	}
+	return float64(i.Fee) / float64(i.Cost)
+}
+
+type FeeEstimate struct {
+	Estimates      []uint64 `json:"estimates"`
+	TargetTimes    []uint64 `json:"target_times"`
+	CurrentFeeRate float64  `json:"current_fee_rate"`
+	MempoolSize    uint64   `json:"mempool_size"`
+	MempoolMaxSize uint64   `json:"mempool_max_size"`
+	FullNodeSynced bool     `json:"full_node_synced"`
+	PeakHeight     uint32   `json:"peak_height"`
+}
+
+func (c *RPCClient) GetAllMempoolTxIds(ctx context.Context) ([]Bytes32, error) {
+	var response struct {
+		TxIds []Bytes32 `json:"tx_ids"`
+	}
+
+	err := c.Call(ctx, "get_all_mempool_tx_ids", nil, &response)
+	return response.TxIds, err
+}
+
+func (c *RPCClient) GetMempoolItemByTxId(ctx context.Context, txId Bytes32) (MempoolItem, error) {
+	var response struct {
+		MempoolItem MempoolItem `json:"mempool_item"`
+	}
+
+	err := c.Call(ctx, "get_mempool_item_by_tx_id", map[string]Bytes32{"tx_id": txId}, &response)
+	return response.MempoolItem, err
+}
+
+func (c *RPCClient) GetAllMempoolItems(ctx context.Context) (map[Bytes32]MempoolItem, error) {
+	var response struct {
+		MempoolItems map[Bytes32]MempoolItem `json:"mempool_items"`
+	}
+
+	err := c.Call(ctx, "get_all_mempool_items", nil, &response)
+	return response.MempoolItems, err
+}
+
+// GetFeeEstimate estimates the fee in mojos for a transaction of the given
+// cost to be included within each of targetTimes, in seconds.
+func (c *RPCClient) GetFeeEstimate(ctx context.Context, targetTimes []uint64, cost uint64) (FeeEstimate, error) {
+	var estimate FeeEstimate
+
+	request := struct {
+		TargetTimes []uint64 `json:"target_times"`
+		Cost        uint64   `json:"cost"`
+	}{targetTimes, cost}
+
+	err := c.Call(ctx, "get_fee_estimate", request, &estimate)
+	return estimate, err
+}
+
+var DefaultFeeRateBuckets = []float64{0, 1, 5, 10, 50, 100}
+
+// FeeRateBucket counts the items whose fee rate is at least Min and below
+// the next bucket's Min.
+type FeeRateBucket struct {
+	Min   float64 `json:"min"`
+	Count int     `json:"count"`
+	Cost  uint64  `json:"cost"`
+}
+
+type MempoolSummary struct {
+	Count         int             `json:"count"`
+	TotalCost     uint64          `json:"total_cost"`
+	TotalFees     uint64          `json:"total_fees"`
+	TotalSize     int             `json:"total_size"`
+	MinFeeRate    float64         `json:"min_fee_rate"`
+	MedianFeeRate float64         `json:"median_fee_rate"`
+	MaxFeeRate    float64         `json:"max_fee_rate"`
+	Buckets       []FeeRateBucket `json:"buckets"`
+}
+
+// SummariseMempool aggregates items by fee rate, cost and serialised size,
+// bucketing fee rates by the ascending lower bounds in buckets.
+func SummariseMempool(items map[Bytes32]MempoolItem, buckets []float64) MempoolSummary {
+	if buckets == nil {
+		buckets = DefaultFeeRateBuckets
+	}
+
+	summary := MempoolSummary{Count: len(items)}
+	for _, min := range buckets {
+		summary.Buckets = append(summary.Buckets, FeeRateBucket{Min: min})
+	}
+
+	var rates []float64
+	for _, item := range items {
+		summary.TotalCost += item.Cost
+		summary.TotalFees += item.Fee
+		if serialised, err := item.SpendBundle.Serialise(); err == nil {
+			summary.TotalSize += len(serialised)
+		}
+
+		rate := item.FeeRate()
+		rates = append(rates, rate)
+		for i := len(summary.Buckets) - 1; i >= 0; i-- {
+			if rate >= summary.Buckets[i].Min {
+				summary.Buckets[i].Count++
+				summary.Buckets[i].Cost += item.Cost
+				break
+			}
+		}
+	}
+
+	if len(rates) > 0 {
+		sort.Float64s(rates)
+		summary.MinFeeRate = rates[0]
+		summary.MaxFeeRate = rates[len(rates)-1]
+		summary.MedianFeeRate = rates[len(rates)/2]
+		if len(rates)%2 == 0 {
+			summary.MedianFeeRate = (rates[len(rates)/2-1] + rates[len(rates)/2]) / 2
+		}
+	}
+
+	return summary
+}
+
+// DwellTime is how long a transaction was seen in the mempool.
+type DwellTime struct {
+	TxId      Bytes32       `json:"tx_id"`
+	FirstSeen time.Time     `json:"first_seen"`
+	Dwell     time.Duration `json:"dwell"`
+}
+
+// DwellTracker follows transactions across mempool samples to measure how
+// long each stays before it is included or dropped.
+type DwellTracker struct {
+	mu        sync.Mutex
+	firstSeen map[Bytes32]time.Time
+}
+
+// Observe records a sample of mempool tx IDs taken at now and returns the
+// transactions that have left the mempool since the previous sample.
+func (t *DwellTracker) Observe(txIds []Bytes32, now time.Time) []DwellTime {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.firstSeen == nil {
+		t.firstSeen = map[Bytes32]time.Time{}
+	}
+
+	present := map[Bytes32]bool{}
+	for _, txId := range txIds {
+		present[txId] = true
+		if _, ok := t.firstSeen[txId]; !ok {
+			t.firstSeen[txId] = now
+		}
+	}
+
+	var left []DwellTime
+	for txId, firstSeen := range t.firstSeen {
+		if !present[txId] {
+			left = append(left, DwellTime{TxId: txId, FirstSeen: firstSeen, Dwell: now.Sub(firstSeen)})
+			delete(t.firstSeen, txId)
+		}
+	}
+	sort.Slice(left, func(i, j int) bool { return left[i].FirstSeen.Before(left[j].FirstSeen) })
+
+	return left
+}
+
+// Pending returns the transactions still in the mempool and how long they
+// have been there at now, oldest first.
+func (t *DwellTracker) Pending(now time.Time) []DwellTime {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pending := make([]DwellTime, 0, len(t.firstSeen))
+	for txId, firstSeen := range t.firstSeen {
+		pending = append(pending, DwellTime{TxId: txId, FirstSeen: firstSeen, Dwell: now.Sub(firstSeen)})
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].FirstSeen.Before(pending[j].FirstSeen) })
+
+	return pending
+}
+
+// MempoolComparison shows propagation gaps between nodes: Missing lists,
+// per node, the transactions some other node has but it doesn't.
+type MempoolComparison struct {
+	Nodes   []string             `json:"nodes"`
+	Total   int                  `json:"total"`
+	Common  []Bytes32            `json:"common"`
+	Missing map[string][]Bytes32 `json:"missing"`
+}
+
+func CompareMempools(txIds map[string][]Bytes32) MempoolComparison {
+	comparison := MempoolComparison{Nodes: sortedKeys(txIds), Missing: map[string][]Bytes32{}}
+
+	seenBy := map[Bytes32]map[string]bool{}
+	for nodeId, ids := range txIds {
+		for _, txId := range ids {
+			if seenBy[txId] == nil {
+				seenBy[txId] = map[string]bool{}
+			}
+			seenBy[txId][nodeId] = true
+		}
+	}
+
+	all := make([]Bytes32, 0, len(seenBy))
+	for txId := range seenBy {
+		all = append(all, txId)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].String() < all[j].String() })
+	comparison.Total = len(all)
+
+	for _, txId := range all {
+		if len(seenBy[txId]) == len(txIds) {
+			comparison.Common = append(comparison.Common, txId)
+			continue
+		}
+		for _, nodeId := range comparison.Nodes {
+			if !seenBy[txId][nodeId] {
+				comparison.Missing[nodeId] = append(comparison.Missing[nodeId], txId)
+			}
+		}
+	}
+
+	return comparison
+}
+
+// CompareMempools fetches the mempool tx IDs of every running node on the
+// checker's network and compares them. Nodes that can't be queried are
+// left out.
+func (c *ConsensusChecker) CompareMempools(ctx context.Context) (MempoolComparison, error) {
+	clients, _, err := c.clients()
+	if err != nil {
+		return MempoolComparison{}, err
+	}
+	defer closeAll(clients)
+
+	var mu sync.Mutex
+	txIds := map[string][]Bytes32{}
+	each(clients, func(nodeId string, rpc *RPCClient) {
+		ids, err := rpc.GetAllMempoolTxIds(ctx)
+		if err != nil {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		txIds[nodeId] = ids
+	})
+
+	return CompareMempools(txIds), nil
+}
@@ -0,0 +1,69 @@
+package marmotcoreclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummariseMempool(t *testing.T) {
+	bundle := testSpendBundle()
+	items := map[Bytes32]MempoolItem{
+		{1}: {SpendBundle: bundle, Fee: 0, Cost: 1000},
+		{2}: {SpendBundle: bundle, Fee: 3000, Cost: 1000},
+		{3}: {SpendBundle: bundle, Fee: 20000, Cost: 2000},
+		{4}: {SpendBundle: bundle, Fee: 200000, Cost: 1000},
+	}
+
+	summary := SummariseMempool(items, nil)
+
+	serialised, _ := bundle.Serialise()
+	assert.Equal(t, 4, summary.Count)
+	assert.Equal(t, uint64(5000), summary.TotalCost)
+	assert.Equal(t, uint64(223000), summary.TotalFees)
+	assert.Equal(t, 4*len(serialised), summary.TotalSize)
+	assert.Equal(t, 0.0, summary.MinFeeRate)
+	assert.Equal(t, 6.5, summary.MedianFeeRate)
+	assert.Equal(t, 200.0, summary.MaxFeeRate)
+	assert.Equal(t, []FeeRateBucket{
+		{Min: 0, Count: 1, Cost: 1000},
+		{Min: 1, Count: 1, Cost: 1000},
+		{Min: 5},
+		{Min: 10, Count: 1, Cost: 2000},
+		{Min: 50},
+		{Min: 100, Count: 1, Cost: 1000},
+	}, summary.Buckets)
+}
+
+func TestDwellTracker(t *testing.T) {
+	var tracker DwellTracker
+	start := time.Now()
+
+	assert.Empty(t, tracker.Observe([]Bytes32{{1}, {2}}, start))
+	assert.Empty(t, tracker.Observe([]Bytes32{{1}, {2}, {3}}, start.Add(time.Minute)))
+
+	left := tracker.Observe([]Bytes32{{3}}, start.Add(3*time.Minute))
+	assert.Len(t, left, 2)
+	assert.Equal(t, 3*time.Minute, left[0].Dwell)
+
+	pending := tracker.Pending(start.Add(4 * time.Minute))
+	assert.Equal(t, []DwellTime{{TxId: Bytes32{3}, FirstSeen: start.Add(time.Minute), Dwell: 3 * time.Minute}}, pending)
+}
+
+func TestCompareMempools(t *testing.T) {
+	comparison := CompareMempools(map[string][]Bytes32{
+		"a": {{1}, {2}},
+		"b": {{1}},
+		"c": {{1}, {3}},
+	})
+
+	assert.Equal(t, []string{"a", "b", "c"}, comparison.Nodes)
+	assert.Equal(t, 3, comparison.Total)
+	assert.Equal(t, []Bytes32{{1}}, comparison.Common)
+	assert.Equal(t, map[string][]Bytes32{
+		"a": {{3}},
+		"b": {{2}, {3}},
+		"c": {{2}},
+	}, comparison.Missing)
+}
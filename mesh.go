@@ -0,0 +1,367 @@
+package marmotcoreclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+const NodeTypeFullNode = 1
+
+// Connection is a peer connection as get_connections reports it. NodeId is
+// the peer's Chia node ID, not a MarmotCore node ID.
+type Connection struct {
+	NodeId         Bytes32 `json:"node_id"`
+	PeerHost       string  `json:"peer_host"`
+	PeerPort       int     `json:"peer_port"`
+	PeerServerPort int     `json:"peer_server_port"`
+	Type           int     `json:"type"`
+}
+
+func (c *RPCClient) GetConnections(ctx context.Context) ([]Connection, error) {
+	var response struct {
+		Connections []Connection `json:"connections"`
+	}
+
+	err := c.Call(ctx, "get_connections", nil, &response)
+	return response.Connections, err
+}
+
+func (c *RPCClient) OpenConnection(ctx context.Context, host string, port string) error {
+	var portNumber int
+	if _, err := fmt.Sscan(port, &portNumber); err != nil {
+		return fmt.Errorf("invalid port %q: %w", port, err)
+	}
+
+	request := struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	}{host, portNumber}
+
+	return c.Call(ctx, "open_connection", request, nil)
+}
+
+func (c *RPCClient) CloseConnection(ctx context.Context, peerNodeId Bytes32) error {
+	return c.Call(ctx, "close_connection", map[string]Bytes32{"node_id": peerNodeId}, nil)
+}
+
+type MeshTopology string
+
+const (
+	FullMesh MeshTopology = "full_mesh"
+	Ring     MeshTopology = "ring"
+	KRandom  MeshTopology = "k_random"
+)
+
+// MeshEdge is a connection between two fleet nodes, From being the node
+// that opens or closes it.
+type MeshEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// MeshNode is a node's peer set as seen before reconciling. Peers are the
+// other fleet nodes it is connected to; ExternalPeers counts everyone else.
+type MeshNode struct {
+	NodeId        string   `json:"node_id"`
+	Peers         []string `json:"peers"`
+	ExternalPeers int      `json:"external_peers"`
+	Error         string   `json:"error,omitempty"`
+}
+
+type MeshReport struct {
+	Nodes  []MeshNode `json:"nodes"`
+	Opened []MeshEdge `json:"opened"`
+	Closed []MeshEdge `json:"closed"`
+	Errors []string   `json:"errors,omitempty"`
+}
+
+// MeshManager keeps the running nodes of Network peered with each other in
+// Topology. Connections to peers outside the fleet are never touched;
+// connections between fleet nodes that the topology doesn't want are only
+// closed with Prune set.
+type MeshManager struct {
+	Client   MarmotcoreClient
+	RPC      RPCOptions
+	Network  string
+	Topology MeshTopology
+	K        int
+	Seed     int64
+	Prune    bool
+	Interval time.Duration
+	Report   func(MeshReport)
+}
+
+// Validate checks the manager can build a topology: Network is required,
+// since peering nodes across networks is meaningless, Topology has to be
+// known (empty means FullMesh) and KRandom needs a positive K.
+func (m MeshManager) Validate() error {
+	if m.Network == "" {
+		return fmt.Errorf("mesh manager: %w", ErrNetworkRequired)
+	}
+
+	switch m.Topology {
+	case "", FullMesh, Ring:
+	case KRandom:
+		if m.K <= 0 {
+			return fmt.Errorf("mesh manager: k_random needs a positive K, not %d", m.K)
+		}
+	default:
+		return fmt.Errorf("mesh manager: unknown topology %q", m.Topology)
+	}
+
+	return nil
+}
+
+// DesiredEdges returns the undirected edges of the topology over nodeIds,
+// each with From as the lower node ID, and none for a topology Validate
+// rejects. k-random picks are seeded by Seed and the node ID, so they stay
+// put while membership doesn't change.
+func (m MeshManager) DesiredEdges(nodeIds []string) []MeshEdge {
+	sorted := append([]string(nil), nodeIds...)
+	sort.Strings(sorted)
+
+	edges := map[MeshEdge]bool{}
+	add := func(a string, b string) {
+		if a == b {
+			return
+		}
+		if b < a {
+			a, b = b, a
+		}
+		edges[MeshEdge{From: a, To: b}] = true
+	}
+
+	switch m.Topology {
+	case Ring:
+		if len(sorted) > 1 {
+			for i, nodeId := range sorted {
+				add(nodeId, sorted[(i+1)%len(sorted)])
+			}
+		}
+	case KRandom:
+		for _, nodeId := range sorted {
+			sum := sha256.Sum256([]byte(nodeId))
+			random := rand.New(rand.NewSource(m.Seed ^ int64(binary.BigEndian.Uint64(sum[:8]))))
+
+			others := make([]string, 0, len(sorted)-1)
+			for _, other := range sorted {
+				if other != nodeId {
+					others = append(others, other)
+				}
+			}
+			random.Shuffle(len(others), func(i, j int) { others[i], others[j] = others[j], others[i] })
+
+			for i := 0; i < m.K && i < len(others); i++ {
+				add(nodeId, others[i])
+			}
+		}
+	case "", FullMesh:
+		for i, a := range sorted {
+			for _, b := range sorted[i+1:] {
+				add(a, b)
+			}
+		}
+	}
+
+	list := make([]MeshEdge, 0, len(edges))
+	for edge := range edges {
+		list = append(list, edge)
+	}
+	sortEdges(list)
+	return list
+}
+
+func sortEdges(edges []MeshEdge) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+}
+
+type meshMember struct {
+	node        Node
+	rpc         *RPCClient
+	peerId      Bytes32
+	connections []Connection
+	err         error
+}
+
+// Reconcile reads every node's connections, opens the missing edges of the
+// topology and, with Prune, closes fleet connections it doesn't want.
+func (m MeshManager) Reconcile(ctx context.Context) (MeshReport, error) {
+	var report MeshReport
+
+	if err := m.Validate(); err != nil {
+		return report, err
+	}
+
+	nodes, err := m.Client.RunningNodes(m.Network)
+	if err != nil {
+		return report, err
+	}
+
+	members := map[string]*meshMember{}
+	clients := map[string]*RPCClient{}
+	for _, node := range nodes {
+		member := &meshMember{node: node}
+		members[node.NodeId] = member
+
+		member.rpc, member.err = m.Client.RPCClientForNode(node, m.RPC)
+		if member.err == nil {
+			clients[node.NodeId] = member.rpc
+		}
+	}
+	defer closeAll(clients)
+
+	each(clients, func(nodeId string, rpc *RPCClient) {
+		member := members[nodeId]
+
+		state, err := rpc.GetBlockchainState(ctx)
+		if err == nil {
+			member.peerId, err = ParseBytes32(state.NodeId)
+		}
+		if err == nil {
+			member.connections, err = rpc.GetConnections(ctx)
+		}
+		member.err = err
+	})
+
+	byPeerId := map[Bytes32]string{}
+	for nodeId, member := range members {
+		if member.err == nil {
+			byPeerId[member.peerId] = nodeId
+		}
+	}
+
+	connected := map[MeshEdge][]MeshEdge{}
+	for _, nodeId := range sortedKeys(members) {
+		member := members[nodeId]
+		meshNode := MeshNode{NodeId: nodeId, Peers: []string{}}
+		if member.err != nil {
+			meshNode.Error = member.err.Error()
+			report.Nodes = append(report.Nodes, meshNode)
+			continue
+		}
+
+		for _, connection := range member.connections {
+			peer, ok := byPeerId[connection.NodeId]
+			if !ok {
+				if connection.Type == NodeTypeFullNode {
+					meshNode.ExternalPeers++
+				}
+				continue
+			}
+
+			meshNode.Peers = append(meshNode.Peers, peer)
+			edge := MeshEdge{From: nodeId, To: peer}
+			if peer < nodeId {
+				edge = MeshEdge{From: peer, To: nodeId}
+			}
+			connected[edge] = append(connected[edge], MeshEdge{From: nodeId, To: peer})
+		}
+		sort.Strings(meshNode.Peers)
+		report.Nodes = append(report.Nodes, meshNode)
+	}
+
+	var reachable []string
+	for nodeId, member := range members {
+		if member.err == nil {
+			reachable = append(reachable, nodeId)
+		}
+	}
+	desired := map[MeshEdge]bool{}
+	for _, edge := range m.DesiredEdges(reachable) {
+		desired[edge] = true
+		if len(connected[edge]) > 0 {
+			continue
+		}
+
+		from, to := members[edge.From], members[edge.To]
+		port, err := DefaultPeerPort(to.node.Network)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("opening %s to %s: %s", edge.From, edge.To, err))
+			continue
+		}
+		if err := from.rpc.OpenConnection(ctx, to.node.PublicIp, port); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("opening %s to %s: %s", edge.From, edge.To, err))
+			continue
+		}
+		report.Opened = append(report.Opened, edge)
+	}
+
+	if m.Prune {
+		existing := make([]MeshEdge, 0, len(connected))
+		for edge := range connected {
+			existing = append(existing, edge)
+		}
+		sortEdges(existing)
+
+		for _, edge := range existing {
+			if desired[edge] {
+				continue
+			}
+			for _, side := range connected[edge] {
+				if err := members[side.From].rpc.CloseConnection(ctx, members[side.To].peerId); err != nil {
+					report.Errors = append(report.Errors, fmt.Sprintf("closing %s to %s: %s", side.From, side.To, err))
+					continue
+				}
+				report.Closed = append(report.Closed, side)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// DefaultPeerPort is the full node peer port of a network. Unknown
+// networks are an error rather than a guess.
+func DefaultPeerPort(network string) (string, error) {
+	known, err := LookupNetwork(network)
+	if err != nil {
+		return "", err
+	}
+	return known.PeerPort, nil
+}
+
+// Run reconciles every Interval, passing each report to Report, until ctx
+// is done.
+func (m MeshManager) Run(ctx context.Context) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+
+	interval := m.Interval
+	if interval == 0 {
+		interval = 5 * time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := m.Reconcile(ctx)
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+
+		if m.Report != nil {
+			m.Report(report)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
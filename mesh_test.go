@@ -0,0 +1,100 @@
+package marmotcoreclient
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDesiredEdges(t *testing.T) {
+	nodeIds := []string{"d", "b", "a", "c"}
+
+	assert.Len(t, MeshManager{Topology: FullMesh}.DesiredEdges(nodeIds), 6)
+	assert.Equal(t, []MeshEdge{{"a", "b"}, {"a", "d"}, {"b", "c"}, {"c", "d"}}, MeshManager{Topology: Ring}.DesiredEdges(nodeIds))
+	assert.Empty(t, MeshManager{Topology: Ring}.DesiredEdges([]string{"a"}))
+
+	kRandom := MeshManager{Topology: KRandom, K: 1, Seed: 7}
+	edges := kRandom.DesiredEdges(nodeIds)
+	assert.Equal(t, edges, kRandom.DesiredEdges([]string{"a", "b", "c", "d"}))
+
+	degree := map[string]int{}
+	for _, edge := range edges {
+		assert.True(t, edge.From < edge.To)
+		degree[edge.From]++
+		degree[edge.To]++
+	}
+	for _, nodeId := range nodeIds {
+		assert.GreaterOrEqual(t, degree[nodeId], 1)
+	}
+}
+
+func TestMeshReconcile(t *testing.T) {
+	connections := map[string][]Connection{
+		"a": {{NodeId: testPeerId("b"), Type: NodeTypeFullNode}, {NodeId: testPeerId("c"), Type: NodeTypeFullNode}},
+		"b": {{NodeId: testPeerId("a"), Type: NodeTypeFullNode}},
+		"d": {{NodeId: Bytes32{9}, Type: NodeTypeFullNode}, {NodeId: Bytes32{8}, Type: 3}},
+	}
+
+	var mu sync.Mutex
+	var calls []string
+	chain := testChain(1, 0, 1, Bytes32{})
+	fleet := newTestFleet(t, map[string][]BlockRecord{"a": chain, "b": chain, "c": chain, "d": chain}, func(nodeId string, endpoint string, request []byte) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch endpoint {
+		case "get_connections":
+			return map[string]interface{}{"connections": append([]Connection{}, connections[nodeId]...)}
+		case "open_connection":
+			var params struct {
+				Host string
+				Port int
+			}
+			json.Unmarshal(request, &params)
+			calls = append(calls, nodeId+" open "+params.Host)
+			assert.Equal(t, 58444, params.Port)
+			return map[string]interface{}{}
+		case "close_connection":
+			var params struct {
+				NodeId Bytes32 `json:"node_id"`
+			}
+			json.Unmarshal(request, &params)
+			assert.Equal(t, testPeerId("c"), params.NodeId)
+			calls = append(calls, nodeId+" close")
+			return map[string]interface{}{}
+		}
+		return nil
+	})
+
+	manager := MeshManager{Client: fleet.Client, RPC: fleet.RPC, Network: "testnet", Topology: Ring, Prune: true}
+	report, err := manager.Reconcile(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []MeshEdge{{"a", "d"}, {"b", "c"}, {"c", "d"}}, report.Opened)
+	assert.Equal(t, []MeshEdge{{"a", "c"}}, report.Closed)
+	assert.Equal(t, []string{"a open 127.0.0.1", "b open 127.0.0.1", "c open 127.0.0.1", "a close"}, calls)
+
+	assert.Len(t, report.Nodes, 5)
+	assert.Equal(t, MeshNode{NodeId: "a", Peers: []string{"b", "c"}}, report.Nodes[0])
+	assert.Equal(t, MeshNode{NodeId: "d", Peers: []string{}, ExternalPeers: 1}, report.Nodes[3])
+	assert.Equal(t, "keyless", report.Nodes[4].NodeId)
+	assert.NotEmpty(t, report.Nodes[4].Error)
+}
+
+func TestMeshManagerValidate(t *testing.T) {
+	assert.ErrorIs(t, MeshManager{}.Validate(), ErrNetworkRequired)
+	assert.NoError(t, MeshManager{Network: "testnet10"}.Validate())
+	assert.NoError(t, MeshManager{Network: "testnet10", Topology: Ring}.Validate())
+	assert.Error(t, MeshManager{Network: "testnet10", Topology: "star"}.Validate())
+	assert.Error(t, MeshManager{Network: "testnet10", Topology: KRandom}.Validate())
+	assert.NoError(t, MeshManager{Network: "testnet10", Topology: KRandom, K: 2}.Validate())
+
+	assert.Empty(t, MeshManager{Topology: "star"}.DesiredEdges([]string{"a", "b"}))
+
+	_, err := MeshManager{Network: "testnet10", Topology: KRandom}.Reconcile(context.Background())
+	assert.Error(t, err)
+	assert.Error(t, MeshManager{}.Run(context.Background()))
+}
@@ -0,0 +1,103 @@
+package marmotcoreclient
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const MojoPerXCH = 1000000000000
+
+var ErrUnknownNetwork = errors.New("unknown network")
+
+// Network holds the constants of a Chia network, looked up from the
+// free-form Node.Network and CreateNode.Network strings.
+type Network struct {
+	Name             string
+	GenesisChallenge Bytes32
+	AddressPrefix    string
+	RPCPort          string
+	PeerPort         string
+	MojoPerXCH       uint64
+}
+
+// ToXCH converts an amount in mojos to XCH.
+func (n Network) ToXCH(mojos uint64) float64 {
+	return float64(mojos) / float64(n.MojoPerXCH)
+}
+
+func mustBytes32(s string) Bytes32 {
+	b, err := ParseBytes32(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+var networks = map[string]Network{
+	"mainnet": {
+		Name:             "mainnet",
+		GenesisChallenge: mustBytes32("ccd5bb71183532bff220ba46c268991a3ff07eb358e8255a65c30a2dce0e5fbb"),
+		AddressPrefix:    "xch",
+		RPCPort:          DefaultRPCPort,
+		PeerPort:         "8444",
+		MojoPerXCH:       MojoPerXCH,
+	},
+	"testnet10": {
+		Name:             "testnet10",
+		GenesisChallenge: mustBytes32("ae83525ba8d1dd3f09b277de18ca3e43fc0af20d20c4b3e92ef2a48bd291ccb2"),
+		AddressPrefix:    "txch",
+		RPCPort:          DefaultRPCPort,
+		PeerPort:         "58444",
+		MojoPerXCH:       MojoPerXCH,
+	},
+	"testnet11": {
+		Name:             "testnet11",
+		GenesisChallenge: mustBytes32("37a90eb5185a9c4439a91ddc98bbadce7b4feba060d50116a067de66bf236615"),
+		AddressPrefix:    "txch",
+		RPCPort:          DefaultRPCPort,
+		PeerPort:         "58444",
+		MojoPerXCH:       MojoPerXCH,
+	},
+}
+
+// networkAliases maps the names MarmotCore uses onto registry entries.
+// testnet is the current testnet, testnet11.
+var networkAliases = map[string]string{
+	"testnet": "testnet11",
+}
+
+// LookupNetwork returns the constants for a network name or alias.
+func LookupNetwork(name string) (Network, error) {
+	if alias, ok := networkAliases[name]; ok {
+		name = alias
+	}
+
+	network, ok := networks[name]
+	if !ok {
+		return Network{}, fmt.Errorf("%w %q, expected one of %v", ErrUnknownNetwork, name, NetworkNames())
+	}
+	return network, nil
+}
+
+// NetworkNames lists the network names and aliases LookupNetwork accepts.
+func NetworkNames() []string {
+	names := sortedKeys(networks)
+	names = append(names, sortedKeys(networkAliases)...)
+	sort.Strings(names)
+	return names
+}
+
+func (n Node) ChiaNetwork() (Network, error) {
+	return LookupNetwork(n.Network)
+}
+
+// Validate checks the request before it is sent. An empty network is left
+// for the API to default.
+func (c CreateNode) Validate() error {
+	if c.Network == "" {
+		return nil
+	}
+	_, err := LookupNetwork(c.Network)
+	return err
+}
@@ -0,0 +1,58 @@
+package marmotcoreclient
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupNetwork(t *testing.T) {
+	mainnet, err := LookupNetwork("mainnet")
+	assert.NoError(t, err)
+	assert.Equal(t, "xch", mainnet.AddressPrefix)
+	assert.Equal(t, "8444", mainnet.PeerPort)
+	assert.Equal(t, "0xccd5bb71183532bff220ba46c268991a3ff07eb358e8255a65c30a2dce0e5fbb", mainnet.GenesisChallenge.String())
+	assert.Equal(t, 1.5, mainnet.ToXCH(1500000000000))
+
+	testnet, err := LookupNetwork("testnet")
+	assert.NoError(t, err)
+	assert.Equal(t, "testnet11", testnet.Name)
+	assert.Equal(t, "txch", testnet.AddressPrefix)
+
+	_, err = LookupNetwork("simnet")
+	assert.True(t, errors.Is(err, ErrUnknownNetwork))
+	assert.EqualError(t, err, `unknown network "simnet", expected one of [mainnet testnet testnet10 testnet11]`)
+}
+
+func TestUnknownNetworkConstants(t *testing.T) {
+	prefix, err := AddressPrefix("mainnet")
+	assert.NoError(t, err)
+	assert.Equal(t, "xch", prefix)
+	port, err := DefaultPeerPort("testnet10")
+	assert.NoError(t, err)
+	assert.Equal(t, "58444", port)
+
+	_, err = AddressPrefix("simnet")
+	assert.ErrorIs(t, err, ErrUnknownNetwork)
+	_, err = DefaultPeerPort("simnet")
+	assert.ErrorIs(t, err, ErrUnknownNetwork)
+	address, _ := EncodeAddress(PuzzleHash{1}, "xch")
+	_, err = DecodeAddressForNetwork(address, "simnet")
+	assert.ErrorIs(t, err, ErrUnknownNetwork)
+}
+
+func TestCreateNodeRejectsUnknownNetwork(t *testing.T) {
+	Client = &MockClient{}
+	PostFunc = func(url string, contentType string, body io.Reader) (resp *http.Response, err error) {
+		t.Fatal("request sent for an invalid network")
+		return nil, nil
+	}
+
+	mc := MarmotcoreClient{Protocol: "http", Host: "localhost", Port: "3000", ApiVersion: "v1"}
+	_, err := mc.CreateNode(&CreateNode{Region: "us-west-2", InstanceType: "node.small", ChiaVersion: "1.3.*", Network: "mainet"})
+
+	assert.True(t, errors.Is(err, ErrUnknownNetwork))
+}
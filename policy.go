@@ -0,0 +1,369 @@
+package marmotcoreclient
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	PolicyAllow = "allow"
+	PolicyDeny  = "deny"
+)
+
+// PolicyAttributes are the names rule conditions can test. Node attributes
+// come from the CreateNode request, or from the node being deleted; profile
+// and caller describe who is asking.
+var PolicyAttributes = []string{"caller", "chia_version", "instance_type", "network", "node_id", "profile", "region", "state"}
+
+// PolicyCondition matches one attribute. Every field that is set has to
+// match: the value is one of In, none of NotIn and matches Regex. Regex has
+// to match the whole value, as if it started with ^ and ended with $.
+type PolicyCondition struct {
+	In    []string `yaml:"in"`
+	NotIn []string `yaml:"not_in"`
+	Regex string   `yaml:"regex"`
+}
+
+// PolicyRule applies Effect when all of its When conditions match a request
+// for one of its Operations, or for any operation if none are listed.
+type PolicyRule struct {
+	Name       string                     `yaml:"name"`
+	Effect     string                     `yaml:"effect"`
+	Operations []string                   `yaml:"operations"`
+	When       map[string]PolicyCondition `yaml:"when"`
+	Reason     string                     `yaml:"reason"`
+}
+
+// Policy governs CreateNode and DeleteNode. A request is denied if any deny
+// rule matches, otherwise allowed if an allow rule matches, otherwise
+// Default decides. Profile and Caller are who requests are evaluated for;
+// Profile.Client sets them from the profile name and DefaultCaller. The
+// caller is whatever the process says it is, so rules on it guard against
+// mistakes, not against someone who can set their own environment.
+//
+// Networks are compared by canonical name, so a rule on testnet11 also
+// matches a request for its alias. A request without a network is denied
+// by every rule that tests network, since the API would choose it.
+type Policy struct {
+	Default string       `yaml:"default"`
+	Rules   []PolicyRule `yaml:"rules"`
+
+	Profile string `yaml:"-"`
+	Caller  string `yaml:"-"`
+
+	// OnDecision, if set, sees every decision, allowed or not.
+	OnDecision func(PolicyDecision) `yaml:"-"`
+}
+
+// PolicyRequest is one operation to evaluate, e.g. CreateNode with its
+// region, network and so on in Attributes.
+type PolicyRequest struct {
+	Operation  string            `json:"operation"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+// RuleResult explains how one rule applied to a request.
+type RuleResult struct {
+	Rule        string `json:"rule"`
+	Effect      string `json:"effect"`
+	Matched     bool   `json:"matched"`
+	Explanation string `json:"explanation"`
+}
+
+type PolicyDecision struct {
+	Request PolicyRequest `json:"request"`
+	Allowed bool          `json:"allowed"`
+	Reason  string        `json:"reason"`
+	Rules   []RuleResult  `json:"rules"`
+}
+
+func (d PolicyDecision) Explain() string {
+	verdict := "denied"
+	if d.Allowed {
+		verdict = "allowed"
+	}
+
+	lines := []string{fmt.Sprintf("%s %s: %s", d.Request.Operation, verdict, d.Reason)}
+	for _, rule := range d.Rules {
+		lines = append(lines, fmt.Sprintf("  %s (%s): %s", rule.Rule, rule.Effect, rule.Explanation))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PolicyDeniedError is returned instead of sending a request the policy
+// denies.
+type PolicyDeniedError struct {
+	Decision PolicyDecision
+}
+
+func (e *PolicyDeniedError) Error() string {
+	return fmt.Sprintf("%s denied by policy: %s", e.Decision.Request.Operation, e.Decision.Reason)
+}
+
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy Policy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &policy, nil
+}
+
+func (p *Policy) Validate() error {
+	if p.Default != "" && p.Default != PolicyAllow && p.Default != PolicyDeny {
+		return fmt.Errorf("default must be allow or deny, not %q", p.Default)
+	}
+
+	for i, rule := range p.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if rule.Effect != PolicyAllow && rule.Effect != PolicyDeny {
+			return fmt.Errorf("rule %s: effect must be allow or deny, not %q", name, rule.Effect)
+		}
+		for _, operation := range rule.Operations {
+			if operation != "CreateNode" && operation != "DeleteNode" {
+				return fmt.Errorf("rule %s: unknown operation %q", name, operation)
+			}
+		}
+		for attribute, condition := range rule.When {
+			if !contains(PolicyAttributes, attribute) {
+				return fmt.Errorf("rule %s: unknown attribute %q", name, attribute)
+			}
+			if _, err := regexp.Compile(anchored(condition.Regex)); err != nil {
+				return fmt.Errorf("rule %s: %s: %w", name, attribute, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Evaluate decides a request and explains every rule's part in it. Profile
+// and Caller are added to the request's attributes if it doesn't have them.
+func (p *Policy) Evaluate(request PolicyRequest) (PolicyDecision, error) {
+	if err := p.Validate(); err != nil {
+		return PolicyDecision{}, err
+	}
+
+	attributes := map[string]string{"profile": p.Profile, "caller": p.Caller}
+	for name, value := range request.Attributes {
+		attributes[name] = value
+	}
+	request.Attributes = attributes
+
+	decision := PolicyDecision{Request: request}
+	var allowedBy, deniedBy []string
+
+	for i, rule := range p.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		result := RuleResult{Rule: name, Effect: rule.Effect}
+
+		if len(rule.Operations) > 0 && !contains(rule.Operations, request.Operation) {
+			result.Explanation = "does not apply to " + request.Operation
+		} else {
+			result.Matched, result.Explanation = rule.match(attributes)
+
+			// Without a network the API picks one, which no rule on
+			// network can check; deny rather than guess.
+			if _, ok := rule.When["network"]; ok && attributes["network"] == "" {
+				deniedBy = append(deniedBy, name+": the request has no network to check")
+			}
+		}
+
+		if result.Matched {
+			if rule.Effect == PolicyDeny {
+				reason := name
+				if rule.Reason != "" {
+					reason += ": " + rule.Reason
+				}
+				deniedBy = append(deniedBy, reason)
+			} else {
+				allowedBy = append(allowedBy, name)
+			}
+		}
+		decision.Rules = append(decision.Rules, result)
+	}
+
+	switch {
+	case len(deniedBy) > 0:
+		decision.Reason = "denied by " + strings.Join(deniedBy, "; ")
+	case len(allowedBy) > 0:
+		decision.Allowed = true
+		decision.Reason = "allowed by " + strings.Join(allowedBy, ", ")
+	case p.Default == PolicyDeny:
+		decision.Reason = "no allow rule matched and the default is deny"
+	default:
+		decision.Allowed = true
+		decision.Reason = "no rule matched and the default is allow"
+	}
+
+	if p.OnDecision != nil {
+		p.OnDecision(decision)
+	}
+
+	return decision, nil
+}
+
+func (r PolicyRule) match(attributes map[string]string) (bool, string) {
+	if len(r.When) == 0 {
+		return true, "matches every request"
+	}
+
+	var matched []string
+	for _, attribute := range sortedKeys(r.When) {
+		condition := r.When[attribute]
+		if attribute == "network" {
+			condition.In = canonicalNetworks(condition.In)
+			condition.NotIn = canonicalNetworks(condition.NotIn)
+		}
+		ok, explanation := condition.match(attribute, attributes[attribute])
+		if !ok {
+			return false, explanation
+		}
+		matched = append(matched, explanation)
+	}
+
+	return true, strings.Join(matched, ", ")
+}
+
+func (c PolicyCondition) match(attribute, value string) (bool, string) {
+	if c.In != nil && !contains(c.In, value) {
+		return false, fmt.Sprintf("%s %q is not in [%s]", attribute, value, strings.Join(c.In, ", "))
+	}
+	if contains(c.NotIn, value) {
+		return false, fmt.Sprintf("%s %q is in [%s]", attribute, value, strings.Join(c.NotIn, ", "))
+	}
+	if c.Regex != "" && !regexp.MustCompile(anchored(c.Regex)).MatchString(value) {
+		return false, fmt.Sprintf("%s %q does not match /%s/", attribute, value, c.Regex)
+	}
+
+	var parts []string
+	if c.In != nil {
+		parts = append(parts, fmt.Sprintf("is in [%s]", strings.Join(c.In, ", ")))
+	}
+	if c.NotIn != nil {
+		parts = append(parts, fmt.Sprintf("is not in [%s]", strings.Join(c.NotIn, ", ")))
+	}
+	if c.Regex != "" {
+		parts = append(parts, fmt.Sprintf("matches /%s/", c.Regex))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "is set to anything")
+	}
+
+	return true, fmt.Sprintf("%s %q %s", attribute, value, strings.Join(parts, " and "))
+}
+
+// anchored makes a condition's regex match only the whole value.
+func anchored(expr string) string {
+	return "^(?:" + expr + ")$"
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalNetworks replaces network aliases in names with the network
+// they stand for, keeping nil as nil.
+func canonicalNetworks(names []string) []string {
+	if names == nil {
+		return nil
+	}
+	canonical := make([]string, len(names))
+	for i, name := range names {
+		canonical[i] = canonicalNetwork(name)
+	}
+	return canonical
+}
+
+// CreateNodeRequest describes a CreateNode for policy evaluation. The
+// network is named canonically, so rules see testnet as the network it
+// stands for.
+func CreateNodeRequest(createNode CreateNode) PolicyRequest {
+	return PolicyRequest{
+		Operation: "CreateNode",
+		Attributes: map[string]string{
+			"region":        createNode.Region,
+			"instance_type": createNode.InstanceType,
+			"chia_version":  createNode.ChiaVersion,
+			"network":       canonicalNetwork(createNode.Network),
+		},
+	}
+}
+
+// DeleteNodeRequest describes deleting node for policy evaluation.
+func DeleteNodeRequest(node Node) PolicyRequest {
+	return PolicyRequest{
+		Operation: "DeleteNode",
+		Attributes: map[string]string{
+			"node_id":       node.NodeId,
+			"region":        node.Region,
+			"instance_type": node.InstanceType,
+			"chia_version":  node.ChiaVersion,
+			"network":       canonicalNetwork(node.Network),
+			"state":         node.State,
+		},
+	}
+}
+
+// DefaultCaller is $MARMOTCORE_CALLER, or "ci" when $CI is set, as CI
+// systems do, or else the name of the local user.
+func DefaultCaller() string {
+	if caller := os.Getenv("MARMOTCORE_CALLER"); caller != "" {
+		return caller
+	}
+	if os.Getenv("CI") != "" {
+		return "ci"
+	}
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return ""
+}
+
+// forProfile copies the policy for a profile's client.
+func (p *Policy) forProfile(name string) *Policy {
+	policy := *p
+	policy.Profile = name
+	policy.Caller = DefaultCaller()
+	return &policy
+}
+
+func (mc MarmotcoreClient) authorize(request PolicyRequest) error {
+	if mc.Policy == nil {
+		return nil
+	}
+
+	decision, err := mc.Policy.Evaluate(request)
+	if err != nil {
+		return fmt.Errorf("policy: %w", err)
+	}
+	if !decision.Allowed {
+		return &PolicyDeniedError{Decision: decision}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package marmotcoreclient
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPolicy = `
+default: deny
+rules:
+  - name: approved-regions
+    effect: allow
+    operations: [CreateNode]
+    when:
+      region: {in: [us-west-2, eu-west-1]}
+      chia_version: {regex: '1\.(3|4)\..*'}
+  - name: no-mainnet-from-ci
+    effect: deny
+    when:
+      network: {in: [mainnet]}
+      profile: {in: [ci]}
+    reason: mainnet nodes are created by people
+  - name: delete-testnet
+    effect: allow
+    operations: [DeleteNode]
+    when:
+      network: {not_in: [mainnet]}
+`
+
+func loadTestPolicy(t *testing.T) *Policy {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	ioutil.WriteFile(path, []byte(testPolicy), 0600)
+
+	policy, err := LoadPolicy(path)
+	assert.NoError(t, err)
+	return policy
+}
+
+func TestPolicyEvaluate(t *testing.T) {
+	policy := loadTestPolicy(t)
+	policy.Profile = "ci"
+
+	decision, err := policy.Evaluate(CreateNodeRequest(CreateNode{Region: "us-west-2", ChiaVersion: "1.3.*", Network: "testnet"}))
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, "allowed by approved-regions", decision.Reason)
+	assert.Equal(t, []RuleResult{
+		{Rule: "approved-regions", Effect: "allow", Matched: true, Explanation: `chia_version "1.3.*" matches /1\.(3|4)\..*/, region "us-west-2" is in [us-west-2, eu-west-1]`},
+		{Rule: "no-mainnet-from-ci", Effect: "deny", Explanation: `network "testnet11" is not in [mainnet]`},
+		{Rule: "delete-testnet", Effect: "allow", Explanation: "does not apply to CreateNode"},
+	}, decision.Rules)
+
+	decision, _ = policy.Evaluate(CreateNodeRequest(CreateNode{Region: "us-west-2", ChiaVersion: "1.3.*", Network: "mainnet"}))
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, "denied by no-mainnet-from-ci: mainnet nodes are created by people", decision.Reason)
+	assert.Contains(t, decision.Explain(), `no-mainnet-from-ci (deny): network "mainnet" is in [mainnet], profile "ci" is in [ci]`)
+
+	policy.Profile = "prod"
+	decision, _ = policy.Evaluate(CreateNodeRequest(CreateNode{Region: "us-west-2", ChiaVersion: "1.3.*", Network: "mainnet"}))
+	assert.True(t, decision.Allowed)
+
+	decision, _ = policy.Evaluate(CreateNodeRequest(CreateNode{Region: "ap-south-1", ChiaVersion: "1.3.*", Network: "testnet"}))
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, "no allow rule matched and the default is deny", decision.Reason)
+	assert.Equal(t, `region "ap-south-1" is not in [us-west-2, eu-west-1]`, decision.Rules[0].Explanation)
+
+	decision, _ = policy.Evaluate(CreateNodeRequest(CreateNode{Region: "us-west-2", ChiaVersion: "1.2.*"}))
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, `chia_version "1.2.*" does not match /1\.(3|4)\..*/`, decision.Rules[0].Explanation)
+
+	anchored := &Policy{Rules: []PolicyRule{{Effect: "allow", When: map[string]PolicyCondition{"region": {Regex: "us-west"}}}}, Default: "deny"}
+	decision, _ = anchored.Evaluate(CreateNodeRequest(CreateNode{Region: "us-west-2"}))
+	assert.False(t, decision.Allowed)
+
+	node := newNode("testUserId", 1648394251715, "chia-node", "", "us-west-2", "node.small", "1.3.*", "mainnet", "R", false)
+	decision, _ = policy.Evaluate(DeleteNodeRequest(*node))
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, "chia-node", decision.Request.Attributes["node_id"])
+	assert.Equal(t, "prod", decision.Request.Attributes["profile"])
+
+	var seen []PolicyDecision
+	policy.OnDecision = func(decision PolicyDecision) { seen = append(seen, decision) }
+	node.Network = "testnet10"
+	decision, _ = policy.Evaluate(DeleteNodeRequest(*node))
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, []PolicyDecision{decision}, seen)
+}
+
+func TestPolicyCanonicalisesNetworks(t *testing.T) {
+	policy := &Policy{Default: "allow", Rules: []PolicyRule{
+		{Name: "no-testnet11", Effect: "deny", When: map[string]PolicyCondition{"network": {In: []string{"testnet11"}}}},
+		{Name: "no-mainnet", Effect: "deny", When: map[string]PolicyCondition{"network": {In: []string{"mainnet"}}}},
+	}}
+
+	decision, err := policy.Evaluate(CreateNodeRequest(CreateNode{Region: "us-west-2", Network: "testnet"}))
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, "denied by no-testnet11", decision.Reason)
+
+	policy.Rules[0].When["network"] = PolicyCondition{In: []string{"testnet"}}
+	decision, _ = policy.Evaluate(DeleteNodeRequest(Node{NodeId: "a", Network: "testnet11"}))
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, "denied by no-testnet11", decision.Reason)
+
+	decision, _ = policy.Evaluate(DeleteNodeRequest(Node{NodeId: "a", Network: "testnet10"}))
+	assert.True(t, decision.Allowed)
+}
+
+func TestPolicyDeniesMissingNetwork(t *testing.T) {
+	policy := &Policy{Default: "allow", Rules: []PolicyRule{
+		{Name: "no-mainnet", Effect: "deny", When: map[string]PolicyCondition{"network": {In: []string{"mainnet"}}}},
+		{Name: "create-in-us", Effect: "allow", Operations: []string{"CreateNode"}, When: map[string]PolicyCondition{"region": {In: []string{"us-west-2"}}}},
+	}}
+
+	decision, err := policy.Evaluate(CreateNodeRequest(CreateNode{Region: "us-west-2"}))
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, "denied by no-mainnet: the request has no network to check", decision.Reason)
+
+	decision, _ = policy.Evaluate(DeleteNodeRequest(Node{NodeId: "a"}))
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, "denied by no-mainnet: the request has no network to check", decision.Reason)
+
+	policy.Rules = policy.Rules[1:]
+	decision, _ = policy.Evaluate(CreateNodeRequest(CreateNode{Region: "us-west-2"}))
+	assert.True(t, decision.Allowed)
+}
+
+func TestPolicyValidate(t *testing.T) {
+	assert.NoError(t, (&Policy{}).Validate())
+	assert.Error(t, (&Policy{Default: "maybe"}).Validate())
+	assert.Error(t, (&Policy{Rules: []PolicyRule{{Effect: "permit"}}}).Validate())
+	assert.Error(t, (&Policy{Rules: []PolicyRule{{Effect: "deny", Operations: []string{"GetNode"}}}}).Validate())
+	assert.Error(t, (&Policy{Rules: []PolicyRule{{Effect: "deny", When: map[string]PolicyCondition{"colour": {}}}}}).Validate())
+	assert.Error(t, (&Policy{Rules: []PolicyRule{{Effect: "deny", When: map[string]PolicyCondition{"region": {Regex: "("}}}}}).Validate())
+
+	decision, err := (&Policy{}).Evaluate(CreateNodeRequest(CreateNode{}))
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+}
+
+func TestClientEnforcesPolicy(t *testing.T) {
+	mainnet := *newNode("testUserId", 1648394251715, "mainnet-node", "", "us-west-2", "node.small", "1.3.*", "mainnet", "R", false)
+	testnet := *newNode("testUserId", 1648394251715, "testnet-node", "", "us-west-2", "node.small", "1.3.*", "testnet10", "R", false)
+	mockNodesAndKeys(t, []Node{mainnet, testnet}, nil)
+
+	posts, deletes := 0, 0
+	PostFunc = func(url string, contentType string, body io.Reader) (*http.Response, error) {
+		posts++
+		return jsonResponse(200, `{"node_id":"new"}`, nil), nil
+	}
+	DoFunc = func(req *http.Request) (*http.Response, error) {
+		deletes++
+		return jsonResponse(200, `{"deleted":true}`, nil), nil
+	}
+
+	policy := loadTestPolicy(t).forProfile("ci")
+	mc := MarmotcoreClient{Protocol: "http", Host: "localhost", Port: "3000", ApiVersion: "v1", Policy: policy}
+
+	_, err := mc.CreateNode(&CreateNode{Region: "us-west-2", ChiaVersion: "1.3.*", Network: "mainnet"})
+	var denied *PolicyDeniedError
+	assert.True(t, errors.As(err, &denied))
+	assert.Equal(t, "CreateNode denied by policy: denied by no-mainnet-from-ci: mainnet nodes are created by people", err.Error())
+	assert.Equal(t, 0, posts)
+
+	_, err = mc.WithOverride("anything").CreateNode(&CreateNode{Region: "us-west-2", ChiaVersion: "1.3.*", Network: "mainnet"})
+	assert.True(t, errors.As(err, &denied))
+
+	_, err = mc.CreateNode(&CreateNode{Region: "us-west-2", ChiaVersion: "1.3.*", Network: "testnet10"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, posts)
+
+	_, err = mc.DeleteNode("mainnet-node")
+	assert.True(t, errors.As(err, &denied))
+	assert.Equal(t, "mainnet-node", denied.Decision.Request.Attributes["node_id"])
+	assert.Equal(t, 0, deletes)
+
+	_, err = mc.DeleteNode("missing")
+	assert.ErrorIs(t, err, ErrNodeNotFound)
+
+	_, err = mc.DeleteNode("testnet-node")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deletes)
+}
+
+func TestProfileLoadsPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	ioutil.WriteFile(path, []byte(testPolicy), 0600)
+	t.Setenv("MARMOTCORE_CALLER", "github-actions")
+
+	mc, err := Profile{Name: "ci", Endpoint: "https://api.marmotcore.com/v1", PolicyFile: path}.Client()
+	assert.NoError(t, err)
+	assert.Equal(t, "ci", mc.Policy.Profile)
+	assert.Equal(t, "github-actions", mc.Policy.Caller)
+	assert.Len(t, mc.Policy.Rules, 3)
+
+	_, err = Profile{Name: "ci", Endpoint: "https://api.marmotcore.com/v1", PolicyFile: filepath.Join(t.TempDir(), "missing.yaml")}.Client()
+	assert.Error(t, err)
+}
+
+func TestDefaultCaller(t *testing.T) {
+	t.Setenv("MARMOTCORE_CALLER", "")
+	t.Setenv("CI", "true")
+	assert.Equal(t, "ci", DefaultCaller())
+
+	t.Setenv("MARMOTCORE_CALLER", "release-bot")
+	assert.Equal(t, "release-bot", DefaultCaller())
+}
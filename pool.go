@@ -0,0 +1,283 @@
+package marmotcoreclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrNoHealthyMember = errors.New("no healthy node in pool")
+
+type PoolStrategy string
+
+const (
+	RoundRobin   PoolStrategy = "round_robin"
+	LeastLatency PoolStrategy = "least_latency"
+)
+
+// PoolMember is the pool's view of one node.
+type PoolMember struct {
+	NodeId  string        `json:"node_id"`
+	Healthy bool          `json:"healthy"`
+	Synced  bool          `json:"synced"`
+	Latency time.Duration `json:"latency"`
+	Error   string        `json:"error,omitempty"`
+}
+
+type poolMember struct {
+	PoolMember
+	rpc      *RPCClient
+	publicIp string
+}
+
+// RPCPool spreads RPC calls over the running nodes of Network that have a
+// key. Members are health checked every HealthInterval, and only synced
+// nodes that answered receive calls. Membership is refreshed from GetNodes
+// and GetKeys every RefreshInterval.
+type RPCPool struct {
+	Client          MarmotcoreClient
+	RPC             RPCOptions
+	Network         string
+	Strategy        PoolStrategy
+	HealthInterval  time.Duration
+	RefreshInterval time.Duration
+
+	mu      sync.Mutex
+	members map[string]*poolMember
+	next    int
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// Start refreshes membership, checks every member's health and keeps doing
+// both in the background until ctx is done or Close is called.
+func (p *RPCPool) Start(ctx context.Context) error {
+	if err := p.Refresh(ctx); err != nil {
+		return err
+	}
+	p.CheckHealth(ctx)
+
+	healthInterval := p.HealthInterval
+	if healthInterval == 0 {
+		healthInterval = 15 * time.Second
+	}
+	refreshInterval := p.RefreshInterval
+	if refreshInterval == 0 {
+		refreshInterval = time.Minute
+	}
+
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+
+		health := time.NewTicker(healthInterval)
+		defer health.Stop()
+		refresh := time.NewTicker(refreshInterval)
+		defer refresh.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-health.C:
+				p.CheckHealth(ctx)
+			case <-refresh.C:
+				if err := p.Refresh(ctx); err == nil {
+					p.CheckHealth(ctx)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (p *RPCPool) Close() {
+	if p.cancel != nil {
+		p.cancel()
+		<-p.done
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, member := range p.members {
+		member.rpc.Close()
+	}
+}
+
+// Refresh adds running nodes that have a key and drops nodes that were
+// deleted, stopped or lost their key. New members start unhealthy until
+// the next health check.
+func (p *RPCPool) Refresh(ctx context.Context) error {
+	if p.Network == "" {
+		return fmt.Errorf("rpc pool: %w", ErrNetworkRequired)
+	}
+
+	nodes, err := p.Client.RunningNodes(p.Network)
+	if err != nil {
+		return err
+	}
+
+	keys, err := p.Client.GetKeys()
+	if err != nil {
+		return err
+	}
+	keysById := map[string]Key{}
+	for _, key := range keys.Keys {
+		keysById[key.NodeId] = key
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	members := map[string]*poolMember{}
+	for _, node := range nodes {
+		if existing, ok := p.members[node.NodeId]; ok && existing.publicIp == node.PublicIp {
+			members[node.NodeId] = existing
+			continue
+		}
+
+		key, ok := keysById[node.NodeId]
+		if !ok {
+			continue
+		}
+		rpc, err := NewRPCClient(node, key, p.RPC)
+		if err != nil {
+			continue
+		}
+		members[node.NodeId] = &poolMember{PoolMember: PoolMember{NodeId: node.NodeId}, rpc: rpc, publicIp: node.PublicIp}
+	}
+
+	for nodeId, member := range p.members {
+		if members[nodeId] != member {
+			member.rpc.Close()
+		}
+	}
+
+	p.members = members
+	return nil
+}
+
+// CheckHealth calls get_blockchain_state on every member concurrently.
+func (p *RPCPool) CheckHealth(ctx context.Context) {
+	p.mu.Lock()
+	members := make([]*poolMember, 0, len(p.members))
+	for _, member := range p.members {
+		members = append(members, member)
+	}
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, member := range members {
+		wg.Add(1)
+		go func(member *poolMember) {
+			defer wg.Done()
+
+			start := time.Now()
+			state, err := member.rpc.GetBlockchainState(ctx)
+			latency := time.Since(start)
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			member.Error = ""
+			member.Synced = err == nil && state.Sync.Synced
+			member.Healthy = member.Synced
+			switch {
+			case err != nil:
+				member.Error = err.Error()
+			case !state.Sync.Synced:
+				member.Error = "not synced"
+			default:
+				member.observe(latency)
+			}
+		}(member)
+	}
+	wg.Wait()
+}
+
+// observe keeps a moving average so one slow call doesn't swing routing.
+func (m *poolMember) observe(latency time.Duration) {
+	if m.Latency == 0 {
+		m.Latency = latency
+		return
+	}
+	m.Latency = (m.Latency*4 + latency) / 5
+}
+
+func (p *RPCPool) Members() []PoolMember {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	members := make([]PoolMember, 0, len(p.members))
+	for _, nodeId := range sortedKeys(p.members) {
+		members = append(members, p.members[nodeId].PoolMember)
+	}
+	return members
+}
+
+// candidates orders the healthy members by the pool's strategy.
+func (p *RPCPool) candidates() []*poolMember {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var healthy []*poolMember
+	for _, nodeId := range sortedKeys(p.members) {
+		if member := p.members[nodeId]; member.Healthy {
+			healthy = append(healthy, member)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	if p.Strategy == LeastLatency {
+		sort.SliceStable(healthy, func(i, j int) bool { return healthy[i].Latency < healthy[j].Latency })
+		return healthy
+	}
+
+	start := p.next % len(healthy)
+	p.next++
+	return append(healthy[start:], healthy[:start]...)
+}
+
+// Call sends the request to a healthy member, failing over to the next one
+// when a node can't be reached. An error reply from a node that answered is
+// returned as is.
+func (p *RPCPool) Call(ctx context.Context, endpoint string, request interface{}, response interface{}) error {
+	candidates := p.candidates()
+	if len(candidates) == 0 {
+		return fmt.Errorf("%w on %s", ErrNoHealthyMember, p.Network)
+	}
+
+	var lastErr error
+	for _, member := range candidates {
+		start := time.Now()
+		err := member.rpc.Call(ctx, endpoint, request, response)
+		latency := time.Since(start)
+
+		var rpcErr *RPCError
+		if err == nil || errors.As(err, &rpcErr) {
+			p.mu.Lock()
+			member.observe(latency)
+			p.mu.Unlock()
+			return err
+		}
+
+		lastErr = err
+		if ctx.Err() != nil {
+			return err
+		}
+
+		p.mu.Lock()
+		member.Healthy = false
+		member.Error = err.Error()
+		p.mu.Unlock()
+	}
+
+	return lastErr
+}
@@ -0,0 +1,82 @@
+package marmotcoreclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func poolNetworkName(t *testing.T, pool *RPCPool) string {
+	var info struct {
+		NetworkName string `json:"network_name"`
+	}
+	assert.NoError(t, pool.Call(context.Background(), "get_network_info", nil, &info))
+	return info.NetworkName
+}
+
+func TestRPCPool(t *testing.T) {
+	chain := testChain(1, 0, 3, Bytes32{})
+	fleet := newTestFleet(t, map[string][]BlockRecord{"a": chain, "b": chain, "down": nil}, networkNameHandler(map[string]string{"a": "a", "b": "b"}))
+
+	pool := &RPCPool{Client: fleet.Client, RPC: fleet.RPC, Network: "testnet11"}
+	assert.NoError(t, pool.Start(context.Background()))
+	defer pool.Close()
+
+	members := pool.Members()
+	assert.Len(t, members, 3)
+	assert.True(t, members[0].Healthy)
+	assert.False(t, members[2].Healthy)
+	assert.Equal(t, "down", members[2].NodeId)
+	assert.Contains(t, members[2].Error, "not ready")
+
+	var served []string
+	for i := 0; i < 4; i++ {
+		served = append(served, poolNetworkName(t, pool))
+	}
+	assert.Equal(t, []string{"a", "b", "a", "b"}, served)
+
+	pool.Strategy = LeastLatency
+	pool.mu.Lock()
+	pool.members["a"].Latency = 50 * time.Millisecond
+	pool.members["b"].Latency = 5 * time.Millisecond
+	pool.mu.Unlock()
+	assert.Equal(t, "b", poolNetworkName(t, pool))
+
+	var response struct{}
+	var rpcErr *RPCError
+	err := pool.Call(context.Background(), "unknown", nil, &response)
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.True(t, pool.Members()[1].Healthy)
+}
+
+func TestRPCPoolRefresh(t *testing.T) {
+	chain := testChain(1, 0, 3, Bytes32{})
+	fleet := newTestFleet(t, map[string][]BlockRecord{"a": chain, "b": chain}, nil)
+
+	pool := &RPCPool{Client: fleet.Client, RPC: fleet.RPC, Network: "testnet"}
+	assert.NoError(t, pool.Refresh(context.Background()))
+	assert.Len(t, pool.Members(), 2)
+
+	var response struct{}
+	assert.True(t, errors.Is(pool.Call(context.Background(), "get_network_info", nil, &response), ErrNoHealthyMember))
+
+	nodes, _ := fleet.Client.GetNodes()
+	keys, _ := fleet.Client.GetKeys()
+	nodes.Nodes[1].Deleted = true
+	mockNodesAndKeys(t, nodes.Nodes, keys.Keys)
+
+	assert.NoError(t, pool.Refresh(context.Background()))
+	members := pool.Members()
+	assert.Len(t, members, 1)
+	assert.Equal(t, "a", members[0].NodeId)
+}
+
+func TestRPCPoolRequiresNetwork(t *testing.T) {
+	fleet := newTestFleet(t, map[string][]BlockRecord{"a": testChain(1, 0, 1, Bytes32{})}, nil)
+
+	pool := &RPCPool{Client: fleet.Client, RPC: fleet.RPC}
+	assert.ErrorIs(t, pool.Refresh(context.Background()), ErrNetworkRequired)
+}
@@ -0,0 +1,366 @@
+package marmotcoreclient
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultMaxBatch = 5
+
+var (
+	ErrConfirmationRequired = errors.New("deleting a node needs a confirmation matching its node id")
+	ErrConfirmationMismatch = errors.New("confirmation does not match node id")
+	ErrBlastRadius          = errors.New("batch delete exceeds the blast radius")
+)
+
+// DeletionRefusedError means deletion protection refused to delete a node.
+type DeletionRefusedError struct {
+	NodeId string
+	Reason string
+}
+
+func (e *DeletionRefusedError) Error() string {
+	return fmt.Sprintf("refusing to delete node %s: %s", e.NodeId, e.Reason)
+}
+
+// ProtectedSet is a file of nodes that must not be deleted, one entry per
+// line. An entry is either a node id or a selector such as
+// "network=mainnet,region=us-west-2", which protects every node whose
+// attributes all match.
+type ProtectedSet struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// DefaultProtectedNodesPath is protected_nodes next to the default config
+// file.
+func DefaultProtectedNodesPath() (string, error) {
+	path, err := DefaultConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "protected_nodes"), nil
+}
+
+func (s *ProtectedSet) read() ([]string, error) {
+	file, err := os.Open(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if _, err := parseSelector(text); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", s.Path, line, err)
+		}
+		entries = append(entries, text)
+	}
+
+	return entries, scanner.Err()
+}
+
+func (s *ProtectedSet) write(entries []string) error {
+	var b strings.Builder
+	b.WriteString("# node-id or selector, e.g. network=mainnet,region=us-west-2\n")
+	for _, entry := range entries {
+		b.WriteString(entry + "\n")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(s.Path, []byte(b.String()), 0600)
+}
+
+func (s *ProtectedSet) Entries() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.read()
+}
+
+func (s *ProtectedSet) Add(entry string) error {
+	if _, err := parseSelector(entry); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.read()
+	if err != nil {
+		return err
+	}
+	if contains(entries, entry) {
+		return nil
+	}
+
+	return s.write(append(entries, entry))
+}
+
+func (s *ProtectedSet) Remove(entry string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	kept := entries[:0]
+	for _, e := range entries {
+		if e != entry {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == len(entries) {
+		return fmt.Errorf("%s is not protected", entry)
+	}
+
+	return s.write(kept)
+}
+
+// Match returns the first entry protecting node, if any. Networks are
+// compared by canonical name, so network=testnet protects the network
+// testnet stands for.
+func (s *ProtectedSet) Match(node Node) (string, bool, error) {
+	entries, err := s.Entries()
+	if err != nil {
+		return "", false, err
+	}
+
+	attributes := DeleteNodeRequest(node).Attributes
+	for _, entry := range entries {
+		selector, _ := parseSelector(entry)
+		matched := true
+		for name, value := range selector {
+			if name == "network" {
+				value = canonicalNetwork(value)
+			}
+			if attributes[name] != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return entry, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// parseSelector reads an entry as attribute values to match. A plain node
+// id is a selector on node_id.
+func parseSelector(entry string) (map[string]string, error) {
+	if !strings.Contains(entry, "=") {
+		if strings.ContainsAny(entry, " \t,") {
+			return nil, fmt.Errorf("invalid node id %q", entry)
+		}
+		return map[string]string{"node_id": entry}, nil
+	}
+
+	selector := map[string]string{}
+	for _, term := range strings.Split(entry, ",") {
+		parts := strings.SplitN(term, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || !contains(PolicyAttributes, name) || name == "profile" || name == "caller" {
+			return nil, fmt.Errorf("invalid selector term %q", term)
+		}
+		selector[name] = strings.TrimSpace(parts[1])
+	}
+
+	return selector, nil
+}
+
+// DeletionProtection guards DeleteNode. Every delete needs a confirmation
+// matching the node id, and the node is fetched afresh, bypassing the
+// response cache, and refused if it is in the protected set, on one of
+// ProtectNetworks, by name or alias, or has been running longer than
+// ProtectOlderThan. MaxBatch caps DeleteNodes and defaults to
+// DefaultMaxBatch.
+type DeletionProtection struct {
+	ProtectedFile    string        `yaml:"protected_file"`
+	ProtectNetworks  []string      `yaml:"protect_networks"`
+	ProtectOlderThan time.Duration `yaml:"protect_older_than"`
+	MaxBatch         int           `yaml:"max_batch"`
+
+	// PreDelete, if set, runs last and can refuse by returning an error.
+	PreDelete func(Node) error `yaml:"-"`
+}
+
+func (p *DeletionProtection) protectedSet() (*ProtectedSet, error) {
+	path := p.ProtectedFile
+	if path == "" {
+		var err error
+		if path, err = DefaultProtectedNodesPath(); err != nil {
+			return nil, err
+		}
+	}
+	return &ProtectedSet{Path: path}, nil
+}
+
+func (p *DeletionProtection) maxBatch() int {
+	if p.MaxBatch == 0 {
+		return DefaultMaxBatch
+	}
+	return p.MaxBatch
+}
+
+// Check decides whether node, as just fetched, may be deleted.
+func (p *DeletionProtection) Check(node Node, now time.Time) error {
+	set, err := p.protectedSet()
+	if err != nil {
+		return err
+	}
+
+	entry, protected, err := set.Match(node)
+	if err != nil {
+		return err
+	}
+	if protected {
+		return &DeletionRefusedError{NodeId: node.NodeId, Reason: fmt.Sprintf("protected by %q in %s", entry, set.Path)}
+	}
+
+	for _, network := range p.ProtectNetworks {
+		if canonicalNetwork(network) == canonicalNetwork(node.Network) {
+			return &DeletionRefusedError{NodeId: node.NodeId, Reason: fmt.Sprintf("nodes on %s are protected", node.Network)}
+		}
+	}
+
+	if p.ProtectOlderThan > 0 {
+		age := now.Sub(fromMillis(node.CreatedTime))
+		if age > p.ProtectOlderThan {
+			return &DeletionRefusedError{NodeId: node.NodeId, Reason: fmt.Sprintf("node has been running for %s, longer than %s", age.Truncate(time.Minute), p.ProtectOlderThan)}
+		}
+	}
+
+	if p.PreDelete != nil {
+		if err := p.PreDelete(node); err != nil {
+			return &DeletionRefusedError{NodeId: node.NodeId, Reason: err.Error()}
+		}
+	}
+
+	return nil
+}
+
+// fetchNode gets a node straight from the API, skipping the response cache.
+func (mc MarmotcoreClient) fetchNode(nodeId string) (Node, error) {
+	var node NodeResponse
+
+	body, err := mc.call("GetNode", nodeId, 0, func() (*http.Response, error) {
+		return mc.getRequest("/nodes/" + nodeId)
+	})
+	if err != nil {
+		return node.Node, err
+	}
+
+	json.Unmarshal(body, &node)
+	if node.Node.NodeId == "" {
+		return node.Node, fmt.Errorf("%w: %s", ErrNodeNotFound, nodeId)
+	}
+
+	return node.Node, nil
+}
+
+// checkDelete runs deletion protection and the policy against a fresh copy
+// of the node.
+func (mc MarmotcoreClient) checkDelete(nodeId string, confirmation string) error {
+	if mc.Protection == nil && mc.Policy == nil {
+		return nil
+	}
+
+	if mc.Protection != nil {
+		if confirmation == "" {
+			return fmt.Errorf("%w: %s", ErrConfirmationRequired, nodeId)
+		}
+		if confirmation != nodeId {
+			return fmt.Errorf("%w: %q is not %s", ErrConfirmationMismatch, confirmation, nodeId)
+		}
+	}
+
+	Cache.invalidateNode(mc.url(), nodeId)
+	node, err := mc.fetchNode(nodeId)
+	if err != nil {
+		return err
+	}
+
+	if mc.Protection != nil {
+		if err := mc.Protection.Check(node, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	return mc.authorize(DeleteNodeRequest(node))
+}
+
+// DeleteNodeConfirmed deletes a node once confirmation, which has to be the
+// node id typed out again, and the client's DeletionProtection allow it.
+func (mc MarmotcoreClient) DeleteNodeConfirmed(nodeId string, confirmation string) (DeleteNodeResponse, error) {
+	if err := mc.checkDelete(nodeId, confirmation); err != nil {
+		return DeleteNodeResponse{}, err
+	}
+
+	return mc.deleteNode(nodeId)
+}
+
+// DeleteNodes deletes several nodes, each of which needs its id among
+// confirmations when the client has DeletionProtection. Nothing is deleted
+// unless every node passes the checks and, with DeletionProtection, there
+// are no more than its MaxBatch of them. Deletion stops at the first
+// failure, returning the responses so far.
+func (mc MarmotcoreClient) DeleteNodes(nodeIds []string, confirmations []string) ([]DeleteNodeResponse, error) {
+	var unique []string
+	for _, nodeId := range nodeIds {
+		if !contains(unique, nodeId) {
+			unique = append(unique, nodeId)
+		}
+	}
+
+	if mc.Protection != nil {
+		if limit := mc.Protection.maxBatch(); len(unique) > limit {
+			return nil, fmt.Errorf("%w: %d nodes, at most %d", ErrBlastRadius, len(unique), limit)
+		}
+	}
+
+	for _, nodeId := range unique {
+		confirmation := ""
+		if contains(confirmations, nodeId) {
+			confirmation = nodeId
+		}
+		if err := mc.checkDelete(nodeId, confirmation); err != nil {
+			return nil, err
+		}
+	}
+
+	responses := make([]DeleteNodeResponse, 0, len(unique))
+	for _, nodeId := range unique {
+		response, err := mc.deleteNode(nodeId)
+		if err != nil {
+			return responses, fmt.Errorf("deleting %s: %w", nodeId, err)
+		}
+		responses = append(responses, response)
+	}
+
+	return responses, nil
+}
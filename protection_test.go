@@ -0,0 +1,149 @@
+package marmotcoreclient
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProtectedSet(t *testing.T) {
+	set := &ProtectedSet{Path: filepath.Join(t.TempDir(), "marmotcore", "protected_nodes")}
+
+	entries, err := set.Entries()
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	assert.NoError(t, set.Add("prod-node"))
+	assert.NoError(t, set.Add("network=mainnet,region=us-west-2"))
+	assert.NoError(t, set.Add("prod-node"))
+	assert.Error(t, set.Add("colour=blue"))
+	assert.Error(t, set.Add("profile=ci"))
+
+	entries, _ = set.Entries()
+	assert.Equal(t, []string{"prod-node", "network=mainnet,region=us-west-2"}, entries)
+
+	node := *newNode("testUserId", 1648394251715, "prod-node", "", "eu-west-1", "node.small", "1.3.*", "testnet10", "R", false)
+	entry, protected, err := set.Match(node)
+	assert.NoError(t, err)
+	assert.True(t, protected)
+	assert.Equal(t, "prod-node", entry)
+
+	node.NodeId, node.Network = "other", "mainnet"
+	_, protected, _ = set.Match(node)
+	assert.False(t, protected)
+
+	node.Region = "us-west-2"
+	entry, protected, _ = set.Match(node)
+	assert.True(t, protected)
+	assert.Equal(t, "network=mainnet,region=us-west-2", entry)
+
+	assert.NoError(t, set.Add("network=testnet"))
+	node.Network = "testnet11"
+	entry, protected, _ = set.Match(node)
+	assert.True(t, protected)
+	assert.Equal(t, "network=testnet", entry)
+	assert.NoError(t, set.Remove("network=testnet"))
+
+	assert.NoError(t, set.Remove("prod-node"))
+	assert.Error(t, set.Remove("prod-node"))
+	entries, _ = set.Entries()
+	assert.Equal(t, []string{"network=mainnet,region=us-west-2"}, entries)
+}
+
+func TestDeletionProtectionCheck(t *testing.T) {
+	now := time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
+	protection := &DeletionProtection{
+		ProtectedFile:    filepath.Join(t.TempDir(), "protected_nodes"),
+		ProtectNetworks:  []string{"mainnet", "testnet11"},
+		ProtectOlderThan: 30 * 24 * time.Hour,
+	}
+
+	node := *newNode("testUserId", millis(now.Add(-time.Hour)), "chia-node", "", "us-west-2", "node.small", "1.3.*", "testnet10", "R", false)
+	assert.NoError(t, protection.Check(node, now))
+
+	var refused *DeletionRefusedError
+	node.Network = "testnet"
+	assert.True(t, errors.As(protection.Check(node, now), &refused))
+	assert.Equal(t, "refusing to delete node chia-node: nodes on testnet are protected", refused.Error())
+
+	protection.ProtectNetworks = []string{"testnet"}
+	node.Network = "testnet11"
+	assert.True(t, errors.As(protection.Check(node, now), &refused))
+	assert.Equal(t, "nodes on testnet11 are protected", refused.Reason)
+
+	node.Network = "testnet10"
+	node.CreatedTime = millis(now.AddDate(0, -2, 0))
+	assert.True(t, errors.As(protection.Check(node, now), &refused))
+	assert.Contains(t, refused.Reason, "longer than 720h0m0s")
+
+	node.CreatedTime = millis(now.Add(-time.Hour))
+	protection.PreDelete = func(node Node) error { return errors.New("node is still farming") }
+	assert.True(t, errors.As(protection.Check(node, now), &refused))
+	assert.Equal(t, "node is still farming", refused.Reason)
+}
+
+func TestDeleteNodeConfirmed(t *testing.T) {
+	created := millis(time.Now().Add(-time.Hour))
+	nodes := []Node{
+		*newNode("testUserId", created, "node-a", "", "us-west-2", "node.small", "1.3.*", "testnet10", "R", false),
+		*newNode("testUserId", created, "node-b", "", "us-west-2", "node.small", "1.3.*", "testnet10", "R", false),
+		*newNode("testUserId", created, "node-c", "", "us-west-2", "node.small", "1.3.*", "mainnet", "R", false),
+	}
+	mockNodesAndKeys(t, nodes, nil)
+
+	var deleted []string
+	DoFunc = func(req *http.Request) (*http.Response, error) {
+		deleted = append(deleted, req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:])
+		return jsonResponse(200, `{"deleted":true}`, nil), nil
+	}
+
+	set := &ProtectedSet{Path: filepath.Join(t.TempDir(), "protected_nodes")}
+	assert.NoError(t, set.Add("network=mainnet"))
+
+	mc := MarmotcoreClient{Protocol: "http", Host: "localhost", Port: "3000", ApiVersion: "v1",
+		Protection: &DeletionProtection{ProtectedFile: set.Path, MaxBatch: 2}}
+
+	_, err := mc.DeleteNode("node-a")
+	assert.ErrorIs(t, err, ErrConfirmationRequired)
+
+	_, err = mc.DeleteNodeConfirmed("node-a", "node-b")
+	assert.ErrorIs(t, err, ErrConfirmationMismatch)
+
+	_, err = mc.DeleteNodeConfirmed("node-c", "node-c")
+	var refused *DeletionRefusedError
+	assert.True(t, errors.As(err, &refused))
+
+	_, err = mc.DeleteNodeConfirmed("missing", "missing")
+	assert.ErrorIs(t, err, ErrNodeNotFound)
+	assert.Empty(t, deleted)
+
+	response, err := mc.DeleteNodeConfirmed("node-a", "node-a")
+	assert.NoError(t, err)
+	assert.True(t, response.Deleted)
+	assert.Equal(t, []string{"node-a"}, deleted)
+
+	_, err = mc.DeleteNodes([]string{"node-a", "node-b", "node-c"}, []string{"node-a", "node-b", "node-c"})
+	assert.ErrorIs(t, err, ErrBlastRadius)
+
+	_, err = mc.DeleteNodes([]string{"node-a", "node-b"}, []string{"node-a"})
+	assert.ErrorIs(t, err, ErrConfirmationRequired)
+
+	_, err = mc.DeleteNodes([]string{"node-b", "node-c"}, []string{"node-b", "node-c"})
+	assert.True(t, errors.As(err, &refused))
+	assert.Equal(t, []string{"node-a"}, deleted)
+
+	responses, err := mc.DeleteNodes([]string{"node-a", "node-b", "node-a"}, []string{"node-a", "node-b"})
+	assert.NoError(t, err)
+	assert.Len(t, responses, 2)
+	assert.Equal(t, []string{"node-a", "node-a", "node-b"}, deleted)
+
+	mc.Protection = nil
+	responses, err = mc.DeleteNodes([]string{"node-a", "node-b", "node-c"}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, responses, 3)
+}
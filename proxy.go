@@ -0,0 +1,189 @@
+package marmotcoreclient
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReadOnlyEndpoints allows every get_ endpoint and nothing that changes a
+// node, such as push_tx or open_connection.
+var ReadOnlyEndpoints = []string{"get_*"}
+
+// RPCProxy lets tools that can't do mutual TLS call node RPC endpoints. It
+// serves /nodes/{nodeId}/rpc/{endpoint}, forwarding the request body to
+// the node with the node's key as the client certificate. RPC clients are
+// cached per node for CredentialTTL.
+//
+// Allow restricts the endpoints that can be called; an entry ending in *
+// matches by prefix. A nil Allow means ReadOnlyEndpoints, and
+// []string{"*"} permits every endpoint.
+//
+// The proxy is meant for local tools, so it refuses requests that don't
+// come from a loopback address or a Unix socket, requests whose Host is not
+// a loopback address and any request with an Origin header, which keeps
+// web pages from reaching it with cross-origin or DNS rebinding requests.
+// AllowRemote drops the address checks for a proxy that is deliberately
+// reachable from other machines, all of which can then use the node keys.
+type RPCProxy struct {
+	Client        MarmotcoreClient
+	RPC           RPCOptions
+	Allow         []string
+	CredentialTTL time.Duration
+	AllowRemote   bool
+
+	mu      sync.Mutex
+	clients map[string]proxyClient
+}
+
+type proxyClient struct {
+	rpc     *RPCClient
+	expires time.Time
+}
+
+func (p *RPCProxy) allowed(endpoint string) bool {
+	allow := p.Allow
+	if allow == nil {
+		allow = ReadOnlyEndpoints
+	}
+
+	for _, pattern := range allow {
+		if pattern == endpoint || (strings.HasSuffix(pattern, "*") && strings.HasPrefix(endpoint, strings.TrimSuffix(pattern, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RPCProxy) client(nodeId string) (*RPCClient, error) {
+	p.mu.Lock()
+	cached, ok := p.clients[nodeId]
+	p.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.rpc, nil
+	}
+
+	rpc, err := p.Client.NewRPCClient(nodeId, p.RPC)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := p.CredentialTTL
+	if ttl == 0 {
+		ttl = 5 * time.Minute
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.clients == nil {
+		p.clients = map[string]proxyClient{}
+	}
+	if old, ok := p.clients[nodeId]; ok {
+		old.rpc.Close()
+	}
+	p.clients[nodeId] = proxyClient{rpc: rpc, expires: time.Now().Add(ttl)}
+
+	return rpc, nil
+}
+
+// forget drops a node's cached client, so the next request looks up its
+// address and key again.
+func (p *RPCProxy) forget(nodeId string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if cached, ok := p.clients[nodeId]; ok {
+		cached.rpc.Close()
+		delete(p.clients, nodeId)
+	}
+}
+
+// loopbackHost reports whether a Host header names this machine.
+func loopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+
+	if host == "" || strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// loopbackRemote reports whether a request's RemoteAddr is this machine.
+// Requests over a Unix socket have no address and count as local.
+func loopbackRemote(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr == "" || addr == "@"
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func proxyError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	body, _ := json.Marshal(map[string]interface{}{"success": false, "error": message})
+	w.Write(body)
+}
+
+func (p *RPCProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 4 || parts[0] != "nodes" || parts[2] != "rpc" || parts[1] == "" || parts[3] == "" {
+		proxyError(w, http.StatusNotFound, "expected /nodes/{nodeId}/rpc/{endpoint}")
+		return
+	}
+	nodeId, endpoint := parts[1], parts[3]
+
+	local := p.AllowRemote || (loopbackHost(r.Host) && loopbackRemote(r.RemoteAddr))
+	if r.Header.Get("Origin") != "" || !local {
+		proxyError(w, http.StatusForbidden, "only local clients may use this proxy")
+		return
+	}
+
+	if r.Method != http.MethodPost && r.Method != http.MethodGet {
+		proxyError(w, http.StatusMethodNotAllowed, "use POST, or GET for endpoints without parameters")
+		return
+	}
+	if !p.allowed(endpoint) {
+		proxyError(w, http.StatusForbidden, endpoint+" is not allowed through this proxy")
+		return
+	}
+
+	request, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		proxyError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(request) == 0 {
+		request = []byte("{}")
+	}
+
+	rpc, err := p.client(nodeId)
+	switch {
+	case errors.Is(err, ErrNodeNotFound):
+		proxyError(w, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		proxyError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	body, err := rpc.CallRaw(r.Context(), endpoint, request)
+	if err != nil {
+		p.forget(nodeId)
+		proxyError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
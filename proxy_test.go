@@ -0,0 +1,112 @@
+package marmotcoreclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRPCProxy(t *testing.T) {
+	_, port := newTestRPCServer(t, map[string]string{"get_blockchain_state": testBlockchainState})
+
+	node := *newNode("testUserId", 1648394251715, "chia-node", "127.0.0.1", "us-west-2", "node.small", "1.3.*", "testnet", "R", false)
+	mockNodeAndKey(t, node, newTestKeyPair(t, "chia-node", time.Now().Add(-time.Hour), time.Now().Add(time.Hour)))
+
+	keyLookups := 0
+	getFunc := GetFunc
+	GetFunc = func(url string) (*http.Response, error) {
+		if strings.HasSuffix(url, "/keys/chia-node") {
+			keyLookups++
+		}
+		return getFunc(url)
+	}
+
+	proxy := &RPCProxy{
+		Client: MarmotcoreClient{Protocol: "http", Host: "localhost", Port: "3000", ApiVersion: "v1"},
+		RPC:    RPCOptions{Port: port, InsecureSkipVerify: true},
+		Allow:  ReadOnlyEndpoints,
+	}
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	request := func(method string, path string, header ...string) (int, string) {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(`{}`))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		if host := req.Header.Get("Host"); host != "" {
+			req.Host = host
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	status, body := request("POST", "/nodes/chia-node/rpc/get_blockchain_state")
+	assert.Equal(t, 200, status)
+	assert.Equal(t, testBlockchainState, body)
+
+	status, _ = request("GET", "/nodes/chia-node/rpc/get_blockchain_state")
+	assert.Equal(t, 200, status)
+	assert.Equal(t, 1, keyLookups)
+
+	status, body = request("POST", "/nodes/chia-node/rpc/push_tx")
+	assert.Equal(t, 403, status)
+	assert.Equal(t, `{"error":"push_tx is not allowed through this proxy","success":false}`, body)
+
+	status, _ = request("POST", "/nodes/missing/rpc/get_blockchain_state")
+	assert.Equal(t, 404, status)
+
+	status, _ = request("POST", "/nodes/chia-node/get_blockchain_state")
+	assert.Equal(t, 404, status)
+
+	status, _ = request("DELETE", "/nodes/chia-node/rpc/get_blockchain_state")
+	assert.Equal(t, 405, status)
+
+	status, _ = request("POST", "/nodes/chia-node/rpc/get_blockchain_state", "Host", "localhost:8556")
+	assert.Equal(t, 200, status)
+
+	status, _ = request("POST", "/nodes/chia-node/rpc/get_blockchain_state", "Host", "attacker.example:8556")
+	assert.Equal(t, 403, status)
+
+	status, _ = request("POST", "/nodes/chia-node/rpc/get_blockchain_state", "Origin", "https://attacker.example")
+	assert.Equal(t, 403, status)
+
+	recorder := httptest.NewRecorder()
+	remote := httptest.NewRequest("POST", "/nodes/chia-node/rpc/get_blockchain_state", strings.NewReader(`{}`))
+	remote.Host = "localhost:8556"
+	remote.RemoteAddr = "192.168.1.10:51234"
+	proxy.ServeHTTP(recorder, remote)
+	assert.Equal(t, 403, recorder.Code)
+
+	proxy.AllowRemote = true
+	recorder = httptest.NewRecorder()
+	proxy.ServeHTTP(recorder, remote)
+	assert.Equal(t, 200, recorder.Code)
+}
+
+func TestRPCProxyDefaultsToReadOnly(t *testing.T) {
+	proxy := &RPCProxy{}
+	assert.True(t, proxy.allowed("get_blockchain_state"))
+	assert.False(t, proxy.allowed("stop_node"))
+	assert.False(t, proxy.allowed("push_tx"))
+
+	proxy.Allow = []string{"*"}
+	assert.True(t, proxy.allowed("stop_node"))
+
+	assert.True(t, loopbackHost("[::1]:8556"))
+	assert.True(t, loopbackHost("127.0.0.2"))
+	assert.False(t, loopbackHost("192.168.1.10:8556"))
+
+	assert.True(t, loopbackRemote("127.0.0.1:51234"))
+	assert.True(t, loopbackRemote("[::1]:51234"))
+	assert.True(t, loopbackRemote("@"))
+	assert.False(t, loopbackRemote("192.168.1.10:51234"))
+}
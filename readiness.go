@@ -0,0 +1,113 @@
+package marmotcoreclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Readiness is the outcome of one readiness check. Ready means the node is
+// running, answered over RPC with its key and reports itself synced.
+type Readiness struct {
+	NodeId     string  `json:"node_id"`
+	State      string  `json:"state"`
+	Reachable  bool    `json:"reachable"`
+	Synced     bool    `json:"synced"`
+	PeakHeight uint32  `json:"peak_height"`
+	Progress   float64 `json:"progress"`
+	Reason     string  `json:"reason,omitempty"`
+}
+
+func (r Readiness) Ready() bool {
+	return r.Reachable && r.Synced
+}
+
+// ReadinessProbe combines the node's state from GetNode, an RPC call over
+// mutual TLS to its public IP and the sync status from
+// get_blockchain_state.
+type ReadinessProbe struct {
+	Client   MarmotcoreClient
+	RPC      RPCOptions
+	Interval time.Duration
+	Progress func(Readiness)
+}
+
+// Check returns an error only when the node can never become ready, such
+// as when it doesn't exist or has been deleted. Everything else is reported
+// through Readiness.Reason.
+func (p ReadinessProbe) Check(ctx context.Context, nodeId string) (Readiness, error) {
+	readiness := Readiness{NodeId: nodeId}
+
+	node, err := p.Client.GetNode(nodeId)
+	if err != nil {
+		readiness.Reason = err.Error()
+		return readiness, nil
+	}
+	if node.Node.NodeId == "" {
+		return readiness, fmt.Errorf("%w: %s", ErrNodeNotFound, nodeId)
+	}
+	if node.Node.Deleted {
+		return readiness, fmt.Errorf("node %s has been deleted", nodeId)
+	}
+
+	readiness.State = node.Node.State
+	if node.Node.State != "R" || node.Node.PublicIp == "" {
+		readiness.Reason = fmt.Sprintf("node is in state %q", node.Node.State)
+		return readiness, nil
+	}
+
+	rpc, err := p.Client.RPCClientForNode(node.Node, p.RPC)
+	if err != nil {
+		readiness.Reason = err.Error()
+		return readiness, nil
+	}
+	defer rpc.Close()
+
+	state, err := rpc.GetBlockchainState(ctx)
+	if err != nil {
+		readiness.Reason = "RPC unreachable: " + err.Error()
+		return readiness, nil
+	}
+
+	readiness.Reachable = true
+	readiness.Synced = state.Sync.Synced
+	readiness.PeakHeight = state.PeakHeight()
+	readiness.Progress = state.SyncProgress()
+	if !readiness.Synced {
+		readiness.Reason = fmt.Sprintf("syncing %.1f%%", readiness.Progress)
+	}
+
+	return readiness, nil
+}
+
+// WaitUntilSynced polls Check every Interval, reporting each result to
+// Progress, until the node is ready, can never be ready or ctx is done.
+func (p ReadinessProbe) WaitUntilSynced(ctx context.Context, nodeId string) (Readiness, error) {
+	interval := p.Interval
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		readiness, err := p.Check(ctx, nodeId)
+		if err != nil {
+			return readiness, err
+		}
+
+		if p.Progress != nil {
+			p.Progress(readiness)
+		}
+		if readiness.Ready() {
+			return readiness, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return readiness, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package marmotcoreclient_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	marmotcoreclient "github.com/freddiecoleman/marmotcore-client"
+	"github.com/freddiecoleman/marmotcore-client/chiatest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitUntilSynced(t *testing.T) {
+	node, err := chiatest.NewNode("chia-node", "testnet")
+	assert.NoError(t, err)
+	defer node.Close()
+	node.Chain.Generate(1501)
+	node.Chain.SetSyncing(2000)
+
+	api := chiatest.NewAPI(node)
+	defer api.Close()
+
+	var progress []float64
+	probe := marmotcoreclient.ReadinessProbe{
+		Client:   api.Client(),
+		RPC:      node.RPCOptions(),
+		Interval: time.Millisecond,
+		Progress: func(readiness marmotcoreclient.Readiness) {
+			progress = append(progress, readiness.Progress)
+			if readiness.Progress < 100 {
+				node.Chain.Generate(500)
+				node.Chain.SetSyncing(0)
+			}
+		},
+	}
+
+	readiness, err := probe.WaitUntilSynced(context.Background(), "chia-node")
+
+	assert.NoError(t, err)
+	assert.True(t, readiness.Ready())
+	assert.Equal(t, uint32(2000), readiness.PeakHeight)
+	assert.Equal(t, []float64{75, 100}, progress)
+}
+
+func TestReadinessCheck(t *testing.T) {
+	node, err := chiatest.NewNode("chia-node", "testnet")
+	assert.NoError(t, err)
+	defer node.Close()
+
+	api := chiatest.NewAPI(node)
+	defer api.Close()
+	api.Update("chia-node", func(n *marmotcoreclient.Node) {
+		n.State = "P"
+		n.PublicIp = ""
+	})
+
+	probe := marmotcoreclient.ReadinessProbe{
+		Client: api.Client(),
+		RPC:    node.RPCOptions(),
+	}
+
+	readiness, err := probe.Check(context.Background(), "chia-node")
+	assert.NoError(t, err)
+	assert.False(t, readiness.Ready())
+	assert.Equal(t, `node is in state "P"`, readiness.Reason)
+
+	_, err = probe.Check(context.Background(), "missing")
+	assert.ErrorIs(t, err, marmotcoreclient.ErrNodeNotFound)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	probe.Interval = time.Millisecond
+	_, err = probe.WaitUntilSynced(ctx, "chia-node")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,299 @@
+package marmotcoreclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+const DefaultRPCPort = "8555"
+
+// rpcIdleConnTimeout closes kept-alive connections to a node that a client
+// holds on to but has stopped using.
+const rpcIdleConnTimeout = 90 * time.Second
+
+var (
+	ErrNoServerVerification = errors.New("RPC options need a TrustStore or InsecureSkipVerify")
+	ErrNodeNotFound         = errors.New("node not found")
+	ErrNoKey                = errors.New("no key for node")
+)
+
+// RPCOptions configure connections to a node's full node RPC API. Chia
+// nodes serve certificates from a private CA, so the server is verified
+// against TrustStore pins, or not at all with InsecureSkipVerify.
+type RPCOptions struct {
+	Port               string
+	TrustStore         *TrustStore
+	InsecureSkipVerify bool
+	Timeout            time.Duration
+
+	// Vault, if set, is where a node's key is looked up first. Keys that
+	// aren't in it are fetched with GetKey.
+	Vault *Vault
+}
+
+// RPCError is a response from the node with success set to false.
+type RPCError struct {
+	NodeId   string
+	Endpoint string
+	Message  string
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s on %s: %s", e.Endpoint, e.NodeId, e.Message)
+}
+
+// RPCClient calls the full node RPC API of a node over mutual TLS, using
+// the node's Key as the client certificate.
+type RPCClient struct {
+	Node   Node
+	url    string
+	client *http.Client
+}
+
+func NewRPCClient(node Node, key Key, opts RPCOptions) (*RPCClient, error) {
+	if opts.TrustStore == nil && !opts.InsecureSkipVerify {
+		return nil, ErrNoServerVerification
+	}
+
+	certificate, err := tlsCertificate(key)
+	if err != nil {
+		return nil, fmt.Errorf("loading key for %s: %w", node.NodeId, err)
+	}
+
+	config := &tls.Config{
+		Certificates:       []tls.Certificate{certificate},
+		InsecureSkipVerify: true,
+	}
+	if opts.TrustStore != nil {
+		config.VerifyConnection = opts.TrustStore.VerifyConnection(node.NodeId, node.PublicIp)
+	}
+
+	port := opts.Port
+	if port == "" {
+		port = DefaultRPCPort
+		if network, err := node.ChiaNetwork(); err == nil {
+			port = network.RPCPort
+		}
+	}
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &RPCClient{
+		Node: node,
+		url:  "https://" + net.JoinHostPort(node.PublicIp, port),
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: config, IdleConnTimeout: rpcIdleConnTimeout},
+		},
+	}, nil
+}
+
+// Close closes the client's idle connections to the node. Each client has
+// its own transport, so callers that are done with one should close it.
+func (c *RPCClient) Close() {
+	c.client.CloseIdleConnections()
+}
+
+func tlsCertificate(key Key) (tls.Certificate, error) {
+	var certificate tls.Certificate
+
+	certPem, err := base64.StdEncoding.DecodeString(key.Cert)
+	if err != nil {
+		return certificate, err
+	}
+
+	err = key.Key.Use(func(keyPem []byte) error {
+		certificate, err = tls.X509KeyPair(certPem, keyPem)
+		return err
+	})
+
+	return certificate, err
+}
+
+// RPCClientForNode fetches the node's key and returns an RPC client for it.
+func (mc MarmotcoreClient) RPCClientForNode(node Node, opts RPCOptions) (*RPCClient, error) {
+	key, err := mc.nodeKey(node.NodeId, opts.Vault)
+	if err != nil {
+		return nil, err
+	}
+	if key.Key.IsZero() || key.Cert == "" {
+		return nil, fmt.Errorf("%w %s", ErrNoKey, node.NodeId)
+	}
+
+	return NewRPCClient(node, key, opts)
+}
+
+// nodeKey reads a node's key from vault, falling back to GetKey when the
+// vault is nil or doesn't have it.
+func (mc MarmotcoreClient) nodeKey(nodeId string, vault *Vault) (Key, error) {
+	if vault != nil {
+		key, err := vault.Get(nodeId)
+		if err == nil || !errors.Is(err, ErrKeyNotInVault) {
+			return key, err
+		}
+	}
+
+	key, err := mc.GetKey(nodeId)
+	return key.Key, err
+}
+
+// NewRPCClient looks up a node and its key and returns an RPC client for it.
+func (mc MarmotcoreClient) NewRPCClient(nodeId string, opts RPCOptions) (*RPCClient, error) {
+	node, err := mc.GetNode(nodeId)
+	if err != nil {
+		return nil, err
+	}
+	if node.Node.NodeId == "" {
+		return nil, fmt.Errorf("%w: %s", ErrNodeNotFound, nodeId)
+	}
+
+	return mc.RPCClientForNode(node.Node, opts)
+}
+
+// Call posts request as JSON to the endpoint, e.g. get_blockchain_state,
+// and decodes the reply into response.
+func (c *RPCClient) Call(ctx context.Context, endpoint string, request interface{}, response interface{}) error {
+	if request == nil {
+		request = struct{}{}
+	}
+
+	requestBytes, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	body, err := c.CallRaw(ctx, endpoint, requestBytes)
+	if err != nil {
+		return err
+	}
+
+	var status struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return fmt.Errorf("%s on %s: %w", endpoint, c.Node.NodeId, err)
+	}
+	if !status.Success {
+		return &RPCError{NodeId: c.Node.NodeId, Endpoint: endpoint, Message: status.Error}
+	}
+
+	if response == nil {
+		return nil
+	}
+	return json.Unmarshal(body, response)
+}
+
+// CallRaw posts a JSON body to the endpoint and returns the raw reply.
+func (c *RPCClient) CallRaw(ctx context.Context, endpoint string, requestBytes []byte) ([]byte, error) {
+	end := startCall(Call{Operation: "chia." + endpoint, NodeId: c.Node.NodeId})
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url+"/"+endpoint, bytes.NewReader(requestBytes))
+	if err != nil {
+		end(CallResult{Err: err})
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		end(CallResult{BytesSent: len(requestBytes), Err: err})
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+
+	end(CallResult{StatusCode: resp.StatusCode, BytesSent: len(requestBytes), BytesReceived: len(body), Err: err})
+
+	return body, err
+}
+
+type BlockRecord struct {
+	HeaderHash       Bytes32    `json:"header_hash"`
+	PrevHash         Bytes32    `json:"prev_hash"`
+	Height           uint32     `json:"height"`
+	Timestamp        uint64     `json:"timestamp,omitempty"`
+	FarmerPuzzleHash PuzzleHash `json:"farmer_puzzle_hash"`
+	PoolPuzzleHash   PuzzleHash `json:"pool_puzzle_hash"`
+	Fees             uint64     `json:"fees,omitempty"`
+}
+
+type SyncState struct {
+	SyncMode           bool   `json:"sync_mode"`
+	Synced             bool   `json:"synced"`
+	SyncTipHeight      uint32 `json:"sync_tip_height"`
+	SyncProgressHeight uint32 `json:"sync_progress_height"`
+}
+
+type BlockchainState struct {
+	Peak        *BlockRecord `json:"peak"`
+	Sync        SyncState    `json:"sync"`
+	Difficulty  uint64       `json:"difficulty"`
+	MempoolSize int          `json:"mempool_size"`
+	MempoolCost uint64       `json:"mempool_cost"`
+	NodeId      string       `json:"node_id"`
+}
+
+func (s BlockchainState) PeakHeight() uint32 {
+	if s.Peak == nil {
+		return 0
+	}
+	return s.Peak.Height
+}
+
+// SyncProgress is how far the node has synced as a percentage. It is 0
+// when the node is not synced and doesn't know the chain tip yet.
+func (s BlockchainState) SyncProgress() float64 {
+	switch {
+	case s.Sync.Synced:
+		return 100
+	case s.Sync.SyncTipHeight == 0:
+		return 0
+	}
+
+	progress := float64(s.Sync.SyncProgressHeight) / float64(s.Sync.SyncTipHeight) * 100
+	if progress > 100 {
+		progress = 100
+	}
+	return progress
+}
+
+func (c *RPCClient) GetBlockchainState(ctx context.Context) (BlockchainState, error) {
+	var response struct {
+		BlockchainState BlockchainState `json:"blockchain_state"`
+	}
+
+	err := c.Call(ctx, "get_blockchain_state", nil, &response)
+	return response.BlockchainState, err
+}
+
+func (c *RPCClient) GetBlockRecordByHeight(ctx context.Context, height uint32) (BlockRecord, error) {
+	var response struct {
+		BlockRecord BlockRecord `json:"block_record"`
+	}
+
+	err := c.Call(ctx, "get_block_record_by_height", map[string]uint32{"height": height}, &response)
+	return response.BlockRecord, err
+}
+
+func (c *RPCClient) GetBlockRecord(ctx context.Context, headerHash Bytes32) (BlockRecord, error) {
+	var response struct {
+		BlockRecord BlockRecord `json:"block_record"`
+	}
+
+	err := c.Call(ctx, "get_block_record", map[string]Bytes32{"header_hash": headerHash}, &response)
+	return response.BlockRecord, err
+}
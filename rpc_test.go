@@ -0,0 +1,88 @@
+package marmotcoreclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRPCServer serves canned replies per endpoint over TLS and insists
+// on a client certificate, like a Chia full node.
+func newTestRPCServer(t *testing.T, replies map[string]string) (*httptest.Server, string) {
+	return newTestRPCHandlerServer(t, func(endpoint string, request []byte, client *x509.Certificate) string {
+		reply, ok := replies[endpoint]
+		if !ok {
+			reply = `{"success":false,"error":"unknown endpoint"}`
+		}
+		return reply
+	})
+}
+
+// newTestRPCHandlerServer is newTestRPCServer with replies computed from
+// the endpoint, request body and client certificate.
+func newTestRPCHandlerServer(t *testing.T, reply func(endpoint string, request []byte, client *x509.Certificate) string) (*httptest.Server, string) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(reply(r.URL.Path[1:], request, r.TLS.PeerCertificates[0])))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	return server, port
+}
+
+const testBlockchainState = `{"success":true,"blockchain_state":{"peak":{"header_hash":"0xabababababababababababababababababababababababababababababababab","prev_hash":"0xdededededededededededededededededededededededededededededededede","height":1500},"sync":{"sync_mode":true,"synced":false,"sync_tip_height":2000,"sync_progress_height":1500},"difficulty":1024,"mempool_size":3}}`
+
+func TestRPCClientGetBlockchainState(t *testing.T) {
+	_, port := newTestRPCServer(t, map[string]string{"get_blockchain_state": testBlockchainState})
+	node := *newNode("testUserId", 1648394251715, "chia-node", "127.0.0.1", "us-west-2", "node.small", "1.3.*", "testnet", "R", false)
+	key := newTestKeyPair(t, "chia-node", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+
+	rpc, err := NewRPCClient(node, key, RPCOptions{Port: port, InsecureSkipVerify: true})
+	assert.NoError(t, err)
+
+	state, err := rpc.GetBlockchainState(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1500), state.PeakHeight())
+	assert.Equal(t, 75.0, state.SyncProgress())
+	assert.Equal(t, 3, state.MempoolSize)
+
+	err = rpc.Call(context.Background(), "get_network_info", nil, nil)
+	var rpcErr *RPCError
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, "unknown endpoint", rpcErr.Message)
+}
+
+func TestRPCClientVerifiesPins(t *testing.T) {
+	_, port := newTestRPCServer(t, map[string]string{"get_blockchain_state": testBlockchainState})
+	node := *newNode("testUserId", 1648394251715, "chia-node", "127.0.0.1", "us-west-2", "node.small", "1.3.*", "testnet", "R", false)
+	key := newTestKeyPair(t, "chia-node", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+
+	_, err := NewRPCClient(node, key, RPCOptions{Port: port})
+	assert.True(t, errors.Is(err, ErrNoServerVerification))
+
+	store := &TrustStore{Path: filepath.Join(t.TempDir(), "known_nodes"), AutoApprove: true}
+	rpc, _ := NewRPCClient(node, key, RPCOptions{Port: port, TrustStore: store})
+
+	_, err = rpc.GetBlockchainState(context.Background())
+	assert.NoError(t, err)
+
+	ioutil.WriteFile(store.Path, []byte("chia-node 127.0.0.1 sha256:00 approved 2022-03-27T15:17:31Z\n"), 0600)
+	rpc.client.CloseIdleConnections()
+
+	_, err = rpc.GetBlockchainState(context.Background())
+	var mismatch *PinMismatchError
+	assert.True(t, errors.As(err, &mismatch))
+}
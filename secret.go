@@ -0,0 +1,108 @@
+package marmotcoreclient
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+const redacted = "[REDACTED]"
+
+var secretMask cipher.Block
+
+func init() {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	secretMask, _ = aes.NewCipher(key)
+}
+
+// mask XORs b in place with a per-process keystream. It only exists to keep
+// secrets out of reflection-based dumps such as test diffs; it is not a
+// protection against anyone who can read the process memory.
+func mask(b []byte) {
+	iv := make([]byte, aes.BlockSize)
+	cipher.NewCTR(secretMask, iv).XORKeyStream(b, b)
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// Secret holds sensitive text, such as a node's base64 PEM private key.
+// It prints as [REDACTED] through fmt, encoding.TextMarshaler and
+// GoString, and has to be read explicitly with Reveal or Use. JSON
+// encoding keeps the real value so Key stays compatible with /keys.
+type Secret struct {
+	masked string
+}
+
+func NewSecret(value string) Secret {
+	b := []byte(value)
+	mask(b)
+	return Secret{masked: string(b)}
+}
+
+func (s Secret) IsZero() bool {
+	return s.masked == ""
+}
+
+func (s Secret) Reveal() string {
+	b := []byte(s.masked)
+	defer zero(b)
+	mask(b)
+	return string(b)
+}
+
+// Use base64 decodes the secret, passes it to fn and zeroes the decoded
+// buffer afterwards, so fn must not keep a reference to it.
+func (s Secret) Use(fn func(decoded []byte) error) error {
+	b := []byte(s.masked)
+	defer zero(b)
+	mask(b)
+
+	decoded := make([]byte, base64.StdEncoding.DecodedLen(len(b)))
+	defer zero(decoded)
+
+	n, err := base64.StdEncoding.Decode(decoded, b)
+	if err != nil {
+		return fmt.Errorf("decoding secret: %w", err)
+	}
+
+	return fn(decoded[:n])
+}
+
+func (s Secret) String() string {
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return redacted
+}
+
+func (s Secret) Format(f fmt.State, verb rune) {
+	f.Write([]byte(redacted))
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Reveal())
+}
+
+func (s *Secret) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*s = NewSecret(value)
+	return nil
+}
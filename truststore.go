@@ -0,0 +1,257 @@
+package marmotcoreclient
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrPinNotFound = errors.New("no pin for node")
+
+type Pin struct {
+	NodeId      string    `json:"node_id"`
+	PublicIp    string    `json:"public_ip"`
+	Fingerprint string    `json:"fingerprint"`
+	Approved    bool      `json:"approved"`
+	FirstSeen   time.Time `json:"first_seen"`
+}
+
+// PinMismatchError means a node presented a different certificate from
+// the one pinned for it on first contact.
+type PinMismatchError struct {
+	NodeId    string
+	PublicIp  string
+	Pinned    string
+	Presented string
+}
+
+func (e *PinMismatchError) Error() string {
+	return fmt.Sprintf("WARNING: NODE IDENTITY HAS CHANGED for %s (%s): pinned %s but the node presented %s. "+
+		"Someone may be intercepting the connection, or the node was rebuilt; "+
+		"if this is expected, revoke the pin with 'marmotctl pins revoke %s'",
+		e.NodeId, e.PublicIp, e.Pinned, e.Presented, e.NodeId)
+}
+
+// PinPendingError means a node was seen for the first time and its pin is
+// waiting to be approved.
+type PinPendingError struct {
+	NodeId      string
+	Fingerprint string
+}
+
+func (e *PinPendingError) Error() string {
+	return fmt.Sprintf("pin %s for %s is pending approval: 'marmotctl pins approve %s'", e.Fingerprint, e.NodeId, e.NodeId)
+}
+
+// TrustStore pins the certificate each node presents on first contact, in
+// a known_hosts style file with one pin per line. Chia nodes use a private
+// CA per installation, so the pin replaces normal chain verification. The
+// topmost certificate in the presented chain is pinned, which is the CA
+// when the node sends it and the server certificate otherwise.
+//
+// With AutoApprove set, first contact is trusted immediately. Otherwise
+// the pin is recorded as pending and connections fail until it is
+// approved.
+type TrustStore struct {
+	Path        string
+	AutoApprove bool
+
+	mu sync.Mutex
+}
+
+// DefaultTrustStorePath is known_nodes next to the default config file.
+func DefaultTrustStorePath() (string, error) {
+	path, err := DefaultConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "known_nodes"), nil
+}
+
+func CertFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func pinId(nodeId string, publicIp string) string {
+	if nodeId != "" {
+		return nodeId
+	}
+	return publicIp
+}
+
+func (s *TrustStore) read() (map[string]Pin, error) {
+	pins := map[string]Pin{}
+
+	file, err := os.Open(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return pins, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("%s:%d: expected 5 fields, got %d", s.Path, line, len(fields))
+		}
+
+		firstSeen, err := time.Parse(time.RFC3339, fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", s.Path, line, err)
+		}
+
+		publicIp := fields[1]
+		if publicIp == "-" {
+			publicIp = ""
+		}
+
+		pins[fields[0]] = Pin{
+			NodeId:      fields[0],
+			PublicIp:    publicIp,
+			Fingerprint: fields[2],
+			Approved:    fields[3] == "approved",
+			FirstSeen:   firstSeen,
+		}
+	}
+
+	return pins, scanner.Err()
+}
+
+func (s *TrustStore) write(pins map[string]Pin) error {
+	var b strings.Builder
+	b.WriteString("# node-id public-ip fingerprint status first-seen\n")
+
+	for _, id := range sortedKeys(pins) {
+		pin := pins[id]
+		status := "pending"
+		if pin.Approved {
+			status = "approved"
+		}
+		publicIp := pin.PublicIp
+		if publicIp == "" {
+			publicIp = "-"
+		}
+		fmt.Fprintf(&b, "%s %s %s %s %s\n", id, publicIp, pin.Fingerprint, status, pin.FirstSeen.UTC().Format(time.RFC3339))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(s.Path, []byte(b.String()), 0600)
+}
+
+func (s *TrustStore) Pins() ([]Pin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pins, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Pin, 0, len(pins))
+	for _, pin := range pins {
+		list = append(list, pin)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].NodeId < list[j].NodeId })
+
+	return list, nil
+}
+
+func (s *TrustStore) Approve(id string) error {
+	return s.update(id, func(pins map[string]Pin, pin Pin) {
+		pin.Approved = true
+		pins[id] = pin
+	})
+}
+
+// Revoke forgets a node's pin, so the next connection pins it afresh.
+func (s *TrustStore) Revoke(id string) error {
+	return s.update(id, func(pins map[string]Pin, pin Pin) {
+		delete(pins, id)
+	})
+}
+
+func (s *TrustStore) update(id string, change func(pins map[string]Pin, pin Pin)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pins, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	pin, ok := pins[id]
+	if !ok {
+		return fmt.Errorf("%w %s", ErrPinNotFound, id)
+	}
+
+	change(pins, pin)
+	return s.write(pins)
+}
+
+// Verify checks the certificates a node presented against its pin,
+// pinning them if this is the first contact.
+func (s *TrustStore) Verify(nodeId string, publicIp string, certs []*x509.Certificate) error {
+	if len(certs) == 0 {
+		return errors.New("node presented no certificates")
+	}
+
+	id := pinId(nodeId, publicIp)
+	presented := CertFingerprint(certs[len(certs)-1])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pins, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	pin, ok := pins[id]
+	if !ok {
+		pin = Pin{NodeId: id, PublicIp: publicIp, Fingerprint: presented, Approved: s.AutoApprove, FirstSeen: time.Now()}
+		pins[id] = pin
+		if err := s.write(pins); err != nil {
+			return err
+		}
+	}
+
+	if pin.Fingerprint != presented {
+		return &PinMismatchError{NodeId: id, PublicIp: publicIp, Pinned: pin.Fingerprint, Presented: presented}
+	}
+	if !pin.Approved {
+		return &PinPendingError{NodeId: id, Fingerprint: pin.Fingerprint}
+	}
+
+	return nil
+}
+
+// VerifyConnection is meant for tls.Config.VerifyConnection, together with
+// InsecureSkipVerify to turn off verification against system roots.
+func (s *TrustStore) VerifyConnection(nodeId string, publicIp string) func(tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		return s.Verify(nodeId, publicIp, state.PeerCertificates)
+	}
+}
@@ -0,0 +1,60 @@
+package marmotcoreclient
+
+import (
+	"crypto/x509"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testCertificate(t *testing.T, nodeId string) *x509.Certificate {
+	cert, err := ParseCert(newTestKeyPair(t, nodeId, time.Now().Add(-time.Hour), time.Now().Add(time.Hour)).Cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestTrustStorePinsOnFirstUse(t *testing.T) {
+	store := &TrustStore{Path: filepath.Join(t.TempDir(), "known_nodes"), AutoApprove: true}
+	original := testCertificate(t, "chia-node")
+	replaced := testCertificate(t, "chia-node")
+
+	assert.NoError(t, store.Verify("chia-node", "54.71.136.33", []*x509.Certificate{original}))
+	assert.NoError(t, store.Verify("chia-node", "54.71.136.33", []*x509.Certificate{original}))
+
+	err := store.Verify("chia-node", "54.71.136.33", []*x509.Certificate{replaced})
+	var mismatch *PinMismatchError
+	assert.True(t, errors.As(err, &mismatch))
+	assert.Equal(t, CertFingerprint(original), mismatch.Pinned)
+	assert.Equal(t, CertFingerprint(replaced), mismatch.Presented)
+
+	assert.NoError(t, store.Revoke("chia-node"))
+	assert.NoError(t, store.Verify("chia-node", "54.71.136.33", []*x509.Certificate{replaced}))
+
+	pins, err := store.Pins()
+	assert.NoError(t, err)
+	assert.Len(t, pins, 1)
+	assert.Equal(t, "54.71.136.33", pins[0].PublicIp)
+	assert.Equal(t, CertFingerprint(replaced), pins[0].Fingerprint)
+	assert.True(t, pins[0].Approved)
+}
+
+func TestTrustStoreRequiresApproval(t *testing.T) {
+	store := &TrustStore{Path: filepath.Join(t.TempDir(), "known_nodes")}
+	cert := testCertificate(t, "chia-node")
+
+	err := store.Verify("", "54.71.136.33", []*x509.Certificate{cert})
+	var pending *PinPendingError
+	assert.True(t, errors.As(err, &pending))
+	assert.Equal(t, "54.71.136.33", pending.NodeId)
+
+	assert.NoError(t, store.Approve("54.71.136.33"))
+	assert.NoError(t, store.Verify("", "54.71.136.33", []*x509.Certificate{cert}))
+
+	assert.True(t, errors.Is(store.Approve("unknown"), ErrPinNotFound))
+	assert.True(t, errors.Is(store.Revoke("unknown"), ErrPinNotFound))
+}
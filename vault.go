@@ -0,0 +1,370 @@
+package marmotcoreclient
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const vaultVersion = 1
+
+var (
+	ErrWrongVaultKey  = errors.New("vault master key does not match")
+	ErrKeyNotInVault  = errors.New("no key in vault for node")
+	ErrNoVaultKey     = errors.New("neither MARMOTCORE_VAULT_KEY nor MARMOTCORE_VAULT_PASSPHRASE is set")
+	vaultCheckContent = []byte("marmotcore-vault")
+)
+
+type vaultKDF struct {
+	Name string `json:"name"`
+	Salt []byte `json:"salt"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+}
+
+type sealedRecord struct {
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+type vaultFile struct {
+	Version int                     `json:"version"`
+	KDF     *vaultKDF               `json:"kdf,omitempty"`
+	Check   sealedRecord            `json:"check"`
+	Records map[string]sealedRecord `json:"records"`
+}
+
+// Vault stores Key records in a file, each encrypted with AES-256-GCM
+// under a master key. The master key is either derived from a passphrase
+// with scrypt or supplied directly, e.g. from MARMOTCORE_VAULT_KEY. Node
+// IDs are stored in the clear so they can be listed.
+type Vault struct {
+	path string
+	key  []byte
+	file vaultFile
+}
+
+// DefaultVaultPath is vault.json next to the default config file.
+func DefaultVaultPath() (string, error) {
+	path, err := DefaultConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "vault.json"), nil
+}
+
+// OpenVault opens the vault at path, creating it if it doesn't exist, with
+// a master key derived from passphrase.
+func OpenVault(path string, passphrase []byte) (*Vault, error) {
+	file, exists, err := readVaultFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		kdf, err := newVaultKDF()
+		if err != nil {
+			return nil, err
+		}
+		file.KDF = kdf
+	} else if file.KDF == nil {
+		return nil, fmt.Errorf("%w: vault uses a raw key, not a passphrase", ErrWrongVaultKey)
+	}
+
+	key, err := file.KDF.derive(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return openVault(path, key, file, exists)
+}
+
+// OpenVaultWithKey opens the vault at path, creating it if it doesn't
+// exist, with a raw 32 byte master key.
+func OpenVaultWithKey(path string, key []byte) (*Vault, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("vault key must be 32 bytes, got %d", len(key))
+	}
+
+	file, exists, err := readVaultFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if exists && file.KDF != nil {
+		return nil, fmt.Errorf("%w: vault uses a passphrase, not a raw key", ErrWrongVaultKey)
+	}
+
+	return openVault(path, append([]byte(nil), key...), file, exists)
+}
+
+// OpenVaultFromEnv uses MARMOTCORE_VAULT_KEY, a base64 encoded 32 byte
+// key, or failing that MARMOTCORE_VAULT_PASSPHRASE.
+func OpenVaultFromEnv(path string) (*Vault, error) {
+	if encoded := os.Getenv("MARMOTCORE_VAULT_KEY"); encoded != "" {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("MARMOTCORE_VAULT_KEY: %w", err)
+		}
+		defer zero(key)
+		return OpenVaultWithKey(path, key)
+	}
+
+	if passphrase := os.Getenv("MARMOTCORE_VAULT_PASSPHRASE"); passphrase != "" {
+		return OpenVault(path, []byte(passphrase))
+	}
+
+	return nil, ErrNoVaultKey
+}
+
+func openVault(path string, key []byte, file vaultFile, exists bool) (*Vault, error) {
+	v := &Vault{path: path, key: key, file: file}
+
+	if !exists {
+		check, err := v.seal("", vaultCheckContent)
+		if err != nil {
+			return nil, err
+		}
+		v.file.Check = check
+		return v, v.save()
+	}
+
+	if _, err := v.open("", v.file.Check); err != nil {
+		v.Close()
+		return nil, ErrWrongVaultKey
+	}
+
+	return v, nil
+}
+
+func readVaultFile(path string) (vaultFile, bool, error) {
+	file := vaultFile{Version: vaultVersion, Records: map[string]sealedRecord{}}
+
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return file, false, nil
+	}
+	if err != nil {
+		return file, false, err
+	}
+
+	if err := json.Unmarshal(data, &file); err != nil {
+		return file, false, fmt.Errorf("reading vault %s: %w", path, err)
+	}
+	if file.Version != vaultVersion {
+		return file, false, fmt.Errorf("unsupported vault version %d", file.Version)
+	}
+	if file.Records == nil {
+		file.Records = map[string]sealedRecord{}
+	}
+
+	return file, true, nil
+}
+
+func newVaultKDF() (*vaultKDF, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return &vaultKDF{Name: "scrypt", Salt: salt, N: 1 << 15, R: 8, P: 1}, nil
+}
+
+func (k *vaultKDF) derive(passphrase []byte) ([]byte, error) {
+	if k.Name != "scrypt" {
+		return nil, fmt.Errorf("unsupported vault kdf %q", k.Name)
+	}
+	return scrypt.Key(passphrase, k.Salt, k.N, k.R, k.P, 32)
+}
+
+func (v *Vault) seal(nodeId string, plaintext []byte) (sealedRecord, error) {
+	gcm, err := v.gcm()
+	if err != nil {
+		return sealedRecord{}, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return sealedRecord{}, err
+	}
+
+	return sealedRecord{
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, []byte("marmotcore-vault:"+nodeId)),
+	}, nil
+}
+
+func (v *Vault) open(nodeId string, record sealedRecord) ([]byte, error) {
+	gcm, err := v.gcm()
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Open(nil, record.Nonce, record.Ciphertext, []byte("marmotcore-vault:"+nodeId))
+}
+
+func (v *Vault) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(v.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (v *Vault) save() error {
+	data, err := json.MarshalIndent(v.file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(v.path), 0700); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(v.path), ".vault-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), v.path)
+}
+
+// Add stores keys, replacing any existing record for the same node, e.g.
+// vault.Add(keysResponse.Keys...).
+func (v *Vault) Add(keys ...Key) error {
+	for _, key := range keys {
+		plaintext, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+
+		record, err := v.seal(key.NodeId, plaintext)
+		zero(plaintext)
+		if err != nil {
+			return err
+		}
+
+		v.file.Records[key.NodeId] = record
+	}
+
+	return v.save()
+}
+
+func (v *Vault) Get(nodeId string) (Key, error) {
+	var key Key
+
+	record, ok := v.file.Records[nodeId]
+	if !ok {
+		return key, fmt.Errorf("%w %s", ErrKeyNotInVault, nodeId)
+	}
+
+	plaintext, err := v.open(nodeId, record)
+	if err != nil {
+		return key, fmt.Errorf("decrypting key for %s: %w", nodeId, err)
+	}
+	defer zero(plaintext)
+
+	err = json.Unmarshal(plaintext, &key)
+	return key, err
+}
+
+// List returns the node IDs in the vault, sorted.
+func (v *Vault) List() []string {
+	return sortedKeys(v.file.Records)
+}
+
+func (v *Vault) Remove(nodeId string) error {
+	if _, ok := v.file.Records[nodeId]; !ok {
+		return fmt.Errorf("%w %s", ErrKeyNotInVault, nodeId)
+	}
+
+	delete(v.file.Records, nodeId)
+	return v.save()
+}
+
+// Rekey re-encrypts every record under a master key derived from a new
+// passphrase with a fresh salt.
+func (v *Vault) Rekey(passphrase []byte) error {
+	kdf, err := newVaultKDF()
+	if err != nil {
+		return err
+	}
+
+	key, err := kdf.derive(passphrase)
+	if err != nil {
+		return err
+	}
+
+	return v.rekey(key, kdf)
+}
+
+// RekeyWithKey re-encrypts every record under a new raw 32 byte master key.
+func (v *Vault) RekeyWithKey(key []byte) error {
+	if len(key) != 32 {
+		return fmt.Errorf("vault key must be 32 bytes, got %d", len(key))
+	}
+	return v.rekey(append([]byte(nil), key...), nil)
+}
+
+func (v *Vault) rekey(key []byte, kdf *vaultKDF) error {
+	plaintexts := map[string][]byte{}
+	defer func() {
+		for _, plaintext := range plaintexts {
+			zero(plaintext)
+		}
+	}()
+
+	for nodeId, record := range v.file.Records {
+		plaintext, err := v.open(nodeId, record)
+		if err != nil {
+			return fmt.Errorf("decrypting key for %s: %w", nodeId, err)
+		}
+		plaintexts[nodeId] = plaintext
+	}
+
+	rekeyed := &Vault{path: v.path, key: key, file: vaultFile{Version: vaultVersion, KDF: kdf, Records: map[string]sealedRecord{}}}
+
+	check, err := rekeyed.seal("", vaultCheckContent)
+	if err != nil {
+		return err
+	}
+	rekeyed.file.Check = check
+
+	for nodeId, plaintext := range plaintexts {
+		record, err := rekeyed.seal(nodeId, plaintext)
+		if err != nil {
+			return err
+		}
+		rekeyed.file.Records[nodeId] = record
+	}
+
+	if err := rekeyed.save(); err != nil {
+		return err
+	}
+
+	zero(v.key)
+	v.key = rekeyed.key
+	v.file = rekeyed.file
+	return nil
+}
+
+// Close zeroes the master key held in memory.
+func (v *Vault) Close() {
+	zero(v.key)
+}
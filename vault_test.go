@@ -0,0 +1,97 @@
+package marmotcoreclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVaultAddGetListRemove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+	secret := base64.StdEncoding.EncodeToString([]byte(testPrivateKey))
+
+	vault, err := OpenVault(path, []byte("correct horse"))
+	assert.NoError(t, err)
+
+	err = vault.Add(*newKey("testUserId", "node-b", secret, "cert-b"), *newKey("testUserId", "node-a", secret, "cert-a"))
+	assert.NoError(t, err)
+
+	data, _ := ioutil.ReadFile(path)
+	assert.False(t, bytes.Contains(data, []byte(secret)))
+	assert.False(t, bytes.Contains(data, []byte("cert-a")))
+
+	reopened, err := OpenVault(path, []byte("correct horse"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"node-a", "node-b"}, reopened.List())
+
+	key, err := reopened.Get("node-a")
+	assert.NoError(t, err)
+	assert.Equal(t, *newKey("testUserId", "node-a", secret, "cert-a"), key)
+
+	assert.NoError(t, reopened.Remove("node-a"))
+	_, err = reopened.Get("node-a")
+	assert.True(t, errors.Is(err, ErrKeyNotInVault))
+	assert.True(t, errors.Is(reopened.Remove("node-a"), ErrKeyNotInVault))
+
+	_, err = OpenVault(path, []byte("wrong"))
+	assert.True(t, errors.Is(err, ErrWrongVaultKey))
+}
+
+func TestVaultRekey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+	masterKey := bytes.Repeat([]byte{7}, 32)
+	t.Setenv("MARMOTCORE_VAULT_KEY", base64.StdEncoding.EncodeToString(masterKey))
+
+	vault, err := OpenVaultFromEnv(path)
+	assert.NoError(t, err)
+	assert.NoError(t, vault.Add(*newKey("testUserId", "node-a", "c2VjcmV0", "cert-a")))
+
+	assert.NoError(t, vault.Rekey([]byte("new passphrase")))
+
+	_, err = OpenVaultWithKey(path, masterKey)
+	assert.True(t, errors.Is(err, ErrWrongVaultKey))
+
+	t.Setenv("MARMOTCORE_VAULT_KEY", "")
+	t.Setenv("MARMOTCORE_VAULT_PASSPHRASE", "new passphrase")
+
+	reopened, err := OpenVaultFromEnv(path)
+	assert.NoError(t, err)
+
+	key, err := reopened.Get("node-a")
+	assert.NoError(t, err)
+	assert.Equal(t, "c2VjcmV0", key.Key.Reveal())
+}
+
+func TestRPCClientReadsKeyFromVault(t *testing.T) {
+	_, port := newTestRPCServer(t, map[string]string{"get_blockchain_state": testBlockchainState})
+
+	node := *newNode("testUserId", 1648394251715, "chia-node", "127.0.0.1", "us-west-2", "node.small", "1.3.*", "testnet", "R", false)
+	mockNodeAndKey(t, node, Key{})
+	mc := MarmotcoreClient{Protocol: "http", Host: "localhost", Port: "3000", ApiVersion: "v1"}
+	opts := RPCOptions{Port: port, InsecureSkipVerify: true}
+
+	_, err := mc.RPCClientForNode(node, opts)
+	assert.ErrorIs(t, err, ErrNoKey)
+
+	opts.Vault, err = OpenVault(filepath.Join(t.TempDir(), "vault.json"), []byte("correct horse"))
+	assert.NoError(t, err)
+	defer opts.Vault.Close()
+	assert.NoError(t, opts.Vault.Add(newTestKeyPair(t, "chia-node", time.Now().Add(-time.Hour), time.Now().Add(time.Hour))))
+
+	rpc, err := mc.RPCClientForNode(node, opts)
+	assert.NoError(t, err)
+	_, err = rpc.GetBlockchainState(context.Background())
+	assert.NoError(t, err)
+
+	other := node
+	other.NodeId = "other-node"
+	_, err = mc.RPCClientForNode(other, opts)
+	assert.ErrorIs(t, err, ErrNoKey)
+}
@@ -0,0 +1,200 @@
+package marmotcoreclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const DefaultMaxReorgDepth = 128
+
+var ErrReorgTooDeep = errors.New("reorg deeper than the blocks the watcher remembers")
+
+type BlockEventKind string
+
+const (
+	NewBlock      BlockEventKind = "new_block"
+	BlockRollback BlockEventKind = "rollback"
+)
+
+// BlockEvent is either a NewBlock with its additions and removals, or a
+// BlockRollback telling the consumer to discard every block above the fork
+// point. Blocks after a rollback continue from ForkHeight+1.
+type BlockEvent struct {
+	Kind       BlockEventKind `json:"kind"`
+	Block      BlockRecord    `json:"block"`
+	Additions  []CoinRecord   `json:"additions,omitempty"`
+	Removals   []CoinRecord   `json:"removals,omitempty"`
+	ForkHeight uint32         `json:"fork_height,omitempty"`
+	ForkHash   Bytes32        `json:"fork_hash"`
+}
+
+// BlockCheckpoint is the last block a consumer has processed. A zero
+// HeaderHash means the block is trusted without checking for a reorg.
+type BlockCheckpoint struct {
+	Height     uint32  `json:"height"`
+	HeaderHash Bytes32 `json:"header_hash"`
+}
+
+// BlockWatcher follows the peak of one node by polling
+// get_blockchain_state. It starts after Checkpoint, or at the current
+// peak when Checkpoint is nil, and remembers the last MaxReorgDepth blocks
+// to find the fork point of a reorg.
+type BlockWatcher struct {
+	RPC           *RPCClient
+	Checkpoint    *BlockCheckpoint
+	Interval      time.Duration
+	MaxReorgDepth int
+
+	recent []BlockCheckpoint
+}
+
+// Last is the most recent block emitted, to be saved as the checkpoint for
+// a restart.
+func (w *BlockWatcher) Last() (BlockCheckpoint, bool) {
+	if len(w.recent) == 0 {
+		if w.Checkpoint != nil {
+			return *w.Checkpoint, true
+		}
+		return BlockCheckpoint{}, false
+	}
+	return w.recent[len(w.recent)-1], true
+}
+
+func (w *BlockWatcher) remember(checkpoint BlockCheckpoint) {
+	depth := w.MaxReorgDepth
+	if depth == 0 {
+		depth = DefaultMaxReorgDepth
+	}
+
+	w.recent = append(w.recent, checkpoint)
+	if len(w.recent) > depth {
+		w.recent = w.recent[len(w.recent)-depth:]
+	}
+}
+
+// Run calls handle with each event in order until ctx is done or handle or
+// the node returns an error.
+func (w *BlockWatcher) Run(ctx context.Context, handle func(BlockEvent) error) error {
+	interval := w.Interval
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+
+	if w.Checkpoint != nil && len(w.recent) == 0 {
+		w.recent = append(w.recent, *w.Checkpoint)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.poll(ctx, handle); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *BlockWatcher) poll(ctx context.Context, handle func(BlockEvent) error) error {
+	state, err := w.RPC.GetBlockchainState(ctx)
+	if err != nil {
+		return err
+	}
+	if state.Peak == nil {
+		return nil
+	}
+	peak := state.Peak.Height
+
+	last, ok := w.Last()
+	if !ok {
+		return w.emit(ctx, *state.Peak, handle)
+	}
+
+	reorged, err := w.reorged(ctx, last, peak)
+	if err != nil {
+		return err
+	}
+	if reorged {
+		if err := w.rollback(ctx, peak, handle); err != nil {
+			return err
+		}
+		last, _ = w.Last()
+	}
+
+	for height := last.Height + 1; height <= peak; height++ {
+		block, err := w.RPC.GetBlockRecordByHeight(ctx, height)
+		if err != nil {
+			return err
+		}
+
+		// The chain moved under us; the next poll finds the fork.
+		if !last.HeaderHash.IsZero() && block.PrevHash != last.HeaderHash {
+			return nil
+		}
+
+		if err := w.emit(ctx, block, handle); err != nil {
+			return err
+		}
+		last, _ = w.Last()
+	}
+
+	return nil
+}
+
+func (w *BlockWatcher) reorged(ctx context.Context, last BlockCheckpoint, peak uint32) (bool, error) {
+	// A peak below the last block is usually a node catching up, so a
+	// shorter chain is only checked once it grows back to that height.
+	if peak < last.Height || last.HeaderHash.IsZero() {
+		return false, nil
+	}
+
+	block, err := w.RPC.GetBlockRecordByHeight(ctx, last.Height)
+	if err != nil {
+		return false, err
+	}
+	return block.HeaderHash != last.HeaderHash, nil
+}
+
+func (w *BlockWatcher) rollback(ctx context.Context, peak uint32, handle func(BlockEvent) error) error {
+	for i := len(w.recent) - 1; i >= 0; i-- {
+		candidate := w.recent[i]
+		if candidate.Height > peak {
+			continue
+		}
+
+		block, err := w.RPC.GetBlockRecordByHeight(ctx, candidate.Height)
+		if err != nil {
+			return err
+		}
+		if block.HeaderHash != candidate.HeaderHash {
+			continue
+		}
+
+		w.recent = w.recent[:i+1]
+		return handle(BlockEvent{Kind: BlockRollback, ForkHeight: candidate.Height, ForkHash: candidate.HeaderHash})
+	}
+
+	last, _ := w.Last()
+	return fmt.Errorf("%w: no common block at or below height %d on %s", ErrReorgTooDeep, last.Height, w.RPC.Node.NodeId)
+}
+
+func (w *BlockWatcher) emit(ctx context.Context, block BlockRecord, handle func(BlockEvent) error) error {
+	additions, removals, err := w.RPC.GetAdditionsAndRemovals(ctx, block.HeaderHash)
+	if err != nil {
+		return err
+	}
+
+	if err := handle(BlockEvent{Kind: NewBlock, Block: block, Additions: additions, Removals: removals}); err != nil {
+		return err
+	}
+
+	w.remember(BlockCheckpoint{Height: block.Height, HeaderHash: block.HeaderHash})
+	return nil
+}
@@ -0,0 +1,90 @@
+package marmotcoreclient
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testChain(branch byte, from uint32, to uint32, prev Bytes32) []BlockRecord {
+	var blocks []BlockRecord
+	for height := from; height <= to; height++ {
+		block := BlockRecord{HeaderHash: Bytes32{byte(height), branch}, PrevHash: prev, Height: height}
+		blocks = append(blocks, block)
+		prev = block.HeaderHash
+	}
+	return blocks
+}
+
+func TestBlockWatcher(t *testing.T) {
+	var mu sync.Mutex
+	chain := testChain(1, 0, 3, Bytes32{})
+
+	_, port := newTestRPCHandlerServer(t, func(endpoint string, request []byte, client *x509.Certificate) string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var reply interface{}
+		switch endpoint {
+		case "get_blockchain_state":
+			reply = map[string]interface{}{"blockchain_state": BlockchainState{Peak: &chain[len(chain)-1]}}
+		case "get_block_record_by_height":
+			var params struct{ Height uint32 }
+			json.Unmarshal(request, &params)
+			if int(params.Height) >= len(chain) {
+				return `{"success":false,"error":"not found"}`
+			}
+			reply = map[string]interface{}{"block_record": chain[params.Height]}
+		case "get_additions_and_removals":
+			reply = map[string]interface{}{"additions": []CoinRecord{{ConfirmedBlockIndex: 1}}}
+		}
+
+		body, _ := json.Marshal(reply)
+		return `{"success":true,` + string(body[1:])
+	})
+
+	node := *newNode("testUserId", 1648394251715, "chia-node", "127.0.0.1", "us-west-2", "node.small", "1.3.*", "testnet", "R", false)
+	rpc, err := NewRPCClient(node, newTestKeyPair(t, "chia-node", time.Now().Add(-time.Hour), time.Now().Add(time.Hour)), RPCOptions{Port: port, InsecureSkipVerify: true})
+	assert.NoError(t, err)
+
+	watcher := &BlockWatcher{
+		RPC:        rpc,
+		Checkpoint: &BlockCheckpoint{Height: 1, HeaderHash: chain[1].HeaderHash},
+		Interval:   time.Millisecond,
+	}
+
+	done := errors.New("done")
+	var events []string
+	err = watcher.Run(context.Background(), func(event BlockEvent) error {
+		if event.Kind == BlockRollback {
+			events = append(events, "rollback to "+event.ForkHash.String()[:6])
+			return nil
+		}
+
+		events = append(events, "new "+event.Block.HeaderHash.String()[:6])
+		assert.Len(t, event.Additions, 1)
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch event.Block.HeaderHash {
+		case Bytes32{3, 1}:
+			chain = append(chain[:2], testChain(2, 2, 4, chain[1].HeaderHash)...)
+		case Bytes32{4, 2}:
+			return done
+		}
+		return nil
+	})
+
+	assert.Equal(t, done, err)
+	assert.Equal(t, []string{"new 0x0201", "new 0x0301", "rollback to 0x0101", "new 0x0202", "new 0x0302", "new 0x0402"}, events)
+
+	last, ok := watcher.Last()
+	assert.True(t, ok)
+	assert.Equal(t, BlockCheckpoint{Height: 3, HeaderHash: Bytes32{3, 2}}, last)
+}